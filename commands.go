@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/config"
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+	"github.com/olksndrdevhub/go-api-starter-kit/validation"
+	"golang.org/x/term"
+)
+
+// command is a subcommand of the binary, all commands share the config
+// loader, so every config flag works with every command
+type command struct {
+	name    string
+	summary string
+	// needsDB connects the database and applies the config before run
+	needsDB bool
+	// validate fails the command on an invalid config before it runs
+	validate bool
+	// setup registers the command flags and returns the function running it
+	setup func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error
+}
+
+// errUsage makes main exit with status 2 like flag errors do
+var errUsage = errors.New("invalid usage")
+
+var commands = []command{
+	{
+		name:     "serve",
+		summary:  "run the API server (default)",
+		needsDB:  true,
+		validate: true,
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+			return serve
+		},
+	},
+	{
+		name:     "migrate",
+		summary:  "apply pending database migrations",
+		needsDB:  true,
+		validate: true,
+		setup:    migrateCommand,
+	},
+	{
+		name:     "create-user",
+		summary:  "create a user",
+		needsDB:  true,
+		validate: true,
+		setup:    createUserCommand(false),
+	},
+	{
+		name:     "create-admin",
+		summary:  "create an admin user or promote an existing user",
+		needsDB:  true,
+		validate: true,
+		setup:    createUserCommand(true),
+	},
+	{
+		name:     "reset-password",
+		summary:  "set a new password for a user",
+		needsDB:  true,
+		validate: true,
+		setup:    resetPasswordCommand,
+	},
+	{
+		name:    "generate-key",
+		summary: "generate a JWT secret or an RSA signing key",
+		setup:   generateKeyCommand,
+	},
+	{
+		name:     "issue-token",
+		summary:  "issue an access token for a user, for debugging",
+		needsDB:  true,
+		validate: true,
+		setup:    issueTokenCommand,
+	},
+	{
+		name:    "routes",
+		summary: "print the registered routes",
+		setup:   routesCommand,
+	},
+	{
+		name:    "check-config",
+		summary: "validate the configuration",
+		setup:   checkConfigCommand,
+	},
+	{
+		name:    "print-config",
+		summary: "print the effective configuration with secrets redacted",
+		setup:   printConfigCommand,
+	},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s [command] [flags]\n\ncommands:\n", filepath.Base(os.Args[0]))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nrun \"%s <command> -h\" for the flags of a command\n", filepath.Base(os.Args[0]))
+}
+
+func migrateCommand(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+	status := fs.Bool("status", false, "list applied and pending migrations without applying them")
+
+	return func(ctx context.Context, cfg *config.Config) error {
+		if *status {
+			applied, err := db.AppliedMigrations(ctx)
+			if err != nil {
+				// schema_migrations doesn't exist before the first migrate
+				applied = map[int]bool{}
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS")
+			for _, migration := range db.Migrations() {
+				state := "pending"
+				if applied[migration.Version] {
+					state = "applied"
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\n", migration.Version, migration.Name, state)
+			}
+			return tw.Flush()
+		}
+
+		if err := db.Migrate(ctx); err != nil {
+			return err
+		}
+		fmt.Println("database is up to date")
+		return nil
+	}
+}
+
+// userInput is validated with the same rules as the register endpoint
+type userInput struct {
+	Email     string `json:"email" validate:"required,email,max=254"`
+	Password  string `json:"password" validate:"required,password"`
+	FirstName string `json:"first_name" validate:"max=100"`
+	LastName  string `json:"last_name" validate:"max=100"`
+}
+
+func createUserCommand(admin bool) func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+	return func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+		var input userInput
+		fs.StringVar(&input.Email, "email", "", "user email (required)")
+		fs.StringVar(&input.FirstName, "first-name", "", "first name")
+		fs.StringVar(&input.LastName, "last-name", "", "last name")
+		passwordFlag := fs.String("password", "", "password, prompted or read from stdin when empty (flags show up in ps)")
+
+		return func(ctx context.Context, cfg *config.Config) error {
+			if err := requireFlag("email", input.Email); err != nil {
+				return err
+			}
+			exists, err := db.CheckUserExistsByEmail(ctx, input.Email)
+			if err != nil {
+				return err
+			}
+			// promoting keeps the existing password
+			if exists && admin {
+				user, err := db.GetUserByEmail(ctx, input.Email)
+				if err != nil {
+					return err
+				}
+				if err := db.SetUserAdmin(ctx, user.ID, true); err != nil {
+					return err
+				}
+				fmt.Printf("user %d (%s) is now an admin\n", user.ID, user.Email)
+				return nil
+			}
+			if exists {
+				return fmt.Errorf("user with email %s already exists", input.Email)
+			}
+
+			input.Password, err = readPassword(*passwordFlag)
+			if err != nil {
+				return err
+			}
+			if err := validateInput(input); err != nil {
+				return err
+			}
+
+			hashedPassword, err := utils.HashPassword(ctx, input.Password)
+			if err != nil {
+				return err
+			}
+			user, err := db.CreateUser(ctx, input.Email, hashedPassword, input.FirstName, input.LastName)
+			if err != nil {
+				return err
+			}
+			if admin {
+				if err := db.SetUserAdmin(ctx, user.ID, true); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("created user %d (%s)\n", user.ID, user.Email)
+			return nil
+		}
+	}
+}
+
+func resetPasswordCommand(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+	email := fs.String("email", "", "user email (required)")
+	passwordFlag := fs.String("password", "", "new password, prompted or read from stdin when empty (flags show up in ps)")
+
+	return func(ctx context.Context, cfg *config.Config) error {
+		if err := requireFlag("email", *email); err != nil {
+			return err
+		}
+		user, err := db.GetUserByEmail(ctx, *email)
+		if err != nil {
+			return fmt.Errorf("user with email %q not found: %w", *email, err)
+		}
+
+		password, err := readPassword(*passwordFlag)
+		if err != nil {
+			return err
+		}
+		if err := validateInput(userInput{Email: user.Email, Password: password}); err != nil {
+			return err
+		}
+
+		hashedPassword, err := utils.HashPassword(ctx, password)
+		if err != nil {
+			return err
+		}
+		if err := db.ChangeUserPassword(ctx, user.ID, hashedPassword); err != nil {
+			return err
+		}
+
+		fmt.Printf("password of user %d (%s) was reset\n", user.ID, user.Email)
+		return nil
+	}
+}
+
+func generateKeyCommand(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+	keyType := fs.String("type", "jwt", "jwt for a JWT_SECRET, rsa for an OAUTH_SIGNING_KEY_FILE PEM")
+	bits := fs.Int("bits", 2048, "rsa key size")
+
+	return func(ctx context.Context, cfg *config.Config) error {
+		switch *keyType {
+		case "jwt":
+			secret := make([]byte, 48)
+			if _, err := rand.Read(secret); err != nil {
+				return err
+			}
+			fmt.Println(base64.RawURLEncoding.EncodeToString(secret))
+			return nil
+		case "rsa":
+			if *bits < 2048 {
+				return fmt.Errorf("rsa keys must have at least 2048 bits: %w", errUsage)
+			}
+			key, err := rsa.GenerateKey(rand.Reader, *bits)
+			if err != nil {
+				return err
+			}
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			if err != nil {
+				return err
+			}
+			return pem.Encode(os.Stdout, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		}
+		return fmt.Errorf("unknown key type %q: %w", *keyType, errUsage)
+	}
+}
+
+func issueTokenCommand(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+	email := fs.String("email", "", "user email (required)")
+
+	return func(ctx context.Context, cfg *config.Config) error {
+		if cfg.Source("auth.jwt_secret") == "generated" {
+			return errors.New("JWT_SECRET is not set, the server would not accept a token signed with a random secret")
+		}
+		if err := requireFlag("email", *email); err != nil {
+			return err
+		}
+
+		user, err := db.GetUserByEmail(ctx, *email)
+		if err != nil {
+			return fmt.Errorf("user with email %q not found: %w", *email, err)
+		}
+		token, err := utils.GenerateJWTToken(user.ID, user.Email)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "token for user %d (%s), valid for %s\n", user.ID, user.Email, utils.JWTTokenTTL)
+		fmt.Println(token)
+		return nil
+	}
+}
+
+func routesCommand(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+	return func(ctx context.Context, cfg *config.Config) error {
+		_, routes := SetupRouters(cfg.Server.MetricsAddr == "")
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "METHOD\tPATH")
+		for _, route := range routes {
+			method := route.Method
+			if method == "" {
+				method = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", method, route.Path)
+		}
+		return tw.Flush()
+	}
+}
+
+func checkConfigCommand(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+	pingDB := fs.Bool("ping-db", false, "also check the database connection")
+
+	return func(ctx context.Context, cfg *config.Config) error {
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		if *pingDB {
+			if err := db.InitDB(dbConfig(cfg)); err != nil {
+				return fmt.Errorf("database: %w", err)
+			}
+			defer db.Close()
+		}
+		fmt.Println("configuration is valid")
+		return nil
+	}
+}
+
+// printConfigCommand prints the effective configuration with secrets redacted,
+// validation problems are reported but don't stop the output
+func printConfigCommand(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config) error {
+	return func(ctx context.Context, cfg *config.Config) error {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			return err
+		}
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		return nil
+	}
+}
+
+func requireFlag(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("-%s is required: %w", name, errUsage)
+	}
+	return nil
+}
+
+// readPassword returns the flag value, prompts without echo on a terminal or
+// reads the first line of piped stdin
+func readPassword(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// validateInput formats validation errors for the terminal
+func validateInput(input userInput) error {
+	err := validation.Validate(input)
+	var apiErr *utils.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	messages := make([]string, 0, len(apiErr.Errors))
+	for _, fieldErr := range apiErr.Errors {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return fmt.Errorf("%s: %w", strings.Join(messages, ", "), errUsage)
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"time"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Config is the typed application configuration. Every field has a file key,
+// an env var and a command line flag named after the key, e.g. db.host,
+// DB_HOST and -db.host. Later sources win: default, file, env, flag.
+// secret fields are redacted when the config is printed
+type Config struct {
+	App       AppConfig       `key:"app"`
+	Server    ServerConfig    `key:"server"`
+	Log       LogConfig       `key:"log"`
+	DB        DBConfig        `key:"db"`
+	Auth      AuthConfig      `key:"auth"`
+	OAuth     OAuthConfig     `key:"oauth"`
+	SMTP      SMTPConfig      `key:"smtp"`
+	MagicLink MagicLinkConfig `key:"magic_link"`
+	WebAuthn  WebAuthnConfig  `key:"webauthn"`
+	OIDC      OIDCConfig      `key:"oidc"`
+	TLS       TLSConfig       `key:"tls"`
+	CORS      CORSConfig      `key:"cors"`
+	Headers   HeadersConfig   `key:"security_headers"`
+	RateLimit RateLimitConfig `key:"rate_limit"`
+	Compress  CompressConfig  `key:"compression"`
+	Tracing   TracingConfig   `key:"tracing"`
+
+	// Warnings are non fatal problems found while loading, e.g. a generated secret
+	Warnings []string `key:"-"`
+	// sources records where each value came from, see Print
+	sources map[string]string
+}
+
+type AppConfig struct {
+	Env string `key:"env" env:"APP_ENV" default:"development" usage:"development or production, production enforces strict validation"`
+}
+
+type ServerConfig struct {
+	Port              string        `key:"port" env:"PORT" default:"8000"`
+	ListenAddr        string        `key:"listen_addr" env:"LISTEN_ADDR" usage:"overrides port, also takes unix:/path/to/socket or fd:N"`
+	ReadHeaderTimeout time.Duration `key:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `key:"read_timeout" env:"SERVER_READ_TIMEOUT" default:"30s"`
+	WriteTimeout      time.Duration `key:"write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"60s"`
+	IdleTimeout       time.Duration `key:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"120s"`
+	MaxHeaderBytes    int           `key:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" default:"65536"`
+	HandlerTimeout    time.Duration `key:"handler_timeout" env:"HANDLER_TIMEOUT" default:"30s" usage:"per request timeout for API routes, 0 disables it"`
+	MaxBodyBytes      int64         `key:"max_body_bytes" env:"MAX_REQUEST_BODY_BYTES" default:"1048576"`
+	AllowGzip         bool          `key:"allow_gzip" env:"ALLOW_GZIP_REQUESTS" default:"true"`
+	TrustedProxies    []string      `key:"trusted_proxies" env:"TRUSTED_PROXIES" usage:"CIDRs or addresses of proxies whose X-Forwarded-For/Forwarded headers are trusted, also unix and private"`
+	MetricsAddr       string        `key:"metrics_addr" env:"METRICS_ADDR" usage:"serve metrics on a separate listener instead of /status/metrics"`
+	ShutdownDelay     time.Duration `key:"shutdown_delay" env:"SHUTDOWN_DELAY" default:"5s" usage:"readiness fails this long before draining starts"`
+	ShutdownTimeout   time.Duration `key:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
+}
+
+type LogConfig struct {
+	Format string `key:"format" env:"LOG_FORMAT" default:"json" usage:"json or text"`
+	Level  string `key:"level" env:"LOG_LEVEL" default:"info" usage:"debug, info, warn or error"`
+}
+
+type DBConfig struct {
+	Host     string `key:"host" env:"DB_HOST" default:"localhost"`
+	Port     string `key:"port" env:"DB_PORT" default:"5432"`
+	User     string `key:"user" env:"DB_USER" default:"postgres"`
+	Password string `key:"password" env:"DB_PASSWORD" secret:"true"`
+	Name     string `key:"name" env:"DB_NAME" default:"app"`
+	SSLMode  string `key:"ssl_mode" env:"DB_SSL_MODE" default:"disable"`
+	// AutoMigrate applies pending migrations on serve, disable it to run the
+	// migrate command as a separate deploy step
+	AutoMigrate bool `key:"auto_migrate" env:"DB_AUTO_MIGRATE" default:"true"`
+}
+
+type AuthConfig struct {
+	JWTSecret      string `key:"jwt_secret" env:"JWT_SECRET" secret:"true" usage:"at least 32 bytes, a random one is generated in development when empty"`
+	CookieEnabled  bool   `key:"cookie_enabled" env:"AUTH_COOKIE_ENABLED" default:"false"`
+	CookieDomain   string `key:"cookie_domain" env:"AUTH_COOKIE_DOMAIN"`
+	CookieSecure   bool   `key:"cookie_secure" env:"AUTH_COOKIE_SECURE" default:"true"`
+	CookieSameSite string `key:"cookie_samesite" env:"AUTH_COOKIE_SAMESITE" default:"lax" usage:"strict, lax or none"`
+}
+
+type OAuthConfig struct {
+	Issuer         string `key:"issuer" env:"OAUTH_ISSUER" usage:"defaults to http://localhost:<port>"`
+	SigningKeyFile string `key:"signing_key_file" env:"OAUTH_SIGNING_KEY_FILE" usage:"RSA private key PEM, an ephemeral key is generated when empty"`
+	LoginURL       string `key:"login_url" env:"OAUTH_LOGIN_URL" usage:"login page for /oauth/authorize without a session, gets the authorize url as return_to, login_required is returned to the client when empty"`
+}
+
+type SMTPConfig struct {
+	Host     string `key:"host" env:"SMTP_HOST" usage:"emails are logged when empty"`
+	Port     string `key:"port" env:"SMTP_PORT" default:"587"`
+	Username string `key:"username" env:"SMTP_USERNAME"`
+	Password string `key:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string `key:"from" env:"SMTP_FROM" default:"no-reply@localhost"`
+}
+
+type MagicLinkConfig struct {
+	URL string `key:"url" env:"MAGIC_LINK_URL" usage:"defaults to the built-in verify page"`
+}
+
+type WebAuthnConfig struct {
+	RPID    string   `key:"rp_id" env:"WEBAUTHN_RP_ID" default:"localhost"`
+	RPName  string   `key:"rp_name" env:"WEBAUTHN_RP_NAME" default:"Go API Starter Kit"`
+	Origins []string `key:"origins" env:"WEBAUTHN_ORIGINS" usage:"defaults to http://localhost:<port>"`
+}
+
+// OIDCConfig lists social login providers, each provider has its own keys,
+// e.g. oidc.google.client_id or OIDC_GOOGLE_CLIENT_ID
+type OIDCConfig struct {
+	ProviderNames []string             `key:"providers" env:"OIDC_PROVIDERS"`
+	Providers     []OIDCProviderConfig `key:"-"`
+}
+
+type OIDCProviderConfig struct {
+	Name         string   `key:"-"`
+	Issuer       string   `key:"issuer" env:"ISSUER"`
+	ClientID     string   `key:"client_id" env:"CLIENT_ID"`
+	ClientSecret string   `key:"client_secret" env:"CLIENT_SECRET" secret:"true"`
+	RedirectURL  string   `key:"redirect_url" env:"REDIRECT_URL"`
+	Scopes       []string `key:"scopes" env:"SCOPES"`
+}
+
+type TLSConfig struct {
+	CertFile              string        `key:"cert_file" env:"TLS_CERT_FILE" usage:"enables TLS together with key_file"`
+	KeyFile               string        `key:"key_file" env:"TLS_KEY_FILE"`
+	ReloadInterval        time.Duration `key:"reload_interval" env:"TLS_RELOAD_INTERVAL" default:"30s"`
+	MinVersion            string        `key:"min_version" env:"TLS_MIN_VERSION" default:"1.2"`
+	CipherSuites          []string      `key:"cipher_suites" env:"TLS_CIPHER_SUITES" usage:"TLS 1.2 suites, Go defaults when empty"`
+	ClientAuth            string        `key:"client_auth" env:"TLS_CLIENT_AUTH" default:"none" usage:"none, request, verify_if_given or require"`
+	ClientCAFile          string        `key:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	ClientCertAuth        bool          `key:"client_cert_auth" env:"TLS_CLIENT_CERT_AUTH" default:"false" usage:"authenticate as the user matching the certificate email"`
+	RedirectAddr          string        `key:"redirect_addr" env:"HTTP_REDIRECT_ADDR" usage:"plain HTTP listener redirecting to HTTPS"`
+	PublicPort            string        `key:"public_port" env:"HTTPS_PUBLIC_PORT" usage:"HTTPS port used in redirects, defaults to the listener port"`
+	HSTSMaxAge            time.Duration `key:"hsts_max_age" env:"HSTS_MAX_AGE" default:"8760h"`
+	HSTSIncludeSubdomains bool          `key:"hsts_include_subdomains" env:"HSTS_INCLUDE_SUBDOMAINS" default:"false"`
+	HSTSPreload           bool          `key:"hsts_preload" env:"HSTS_PRELOAD" default:"false"`
+}
+
+// CORSConfig is the policy for browser clients on other origins, CORS
+// headers are not sent when AllowedOrigins is empty
+type CORSConfig struct {
+	AllowedOrigins   []string      `key:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"e.g. https://app.example.com or https://*.example.com, * allows any origin"`
+	AllowedMethods   []string      `key:"allowed_methods" env:"CORS_ALLOWED_METHODS" default:"GET,HEAD,POST,PUT,PATCH,DELETE"`
+	AllowedHeaders   []string      `key:"allowed_headers" env:"CORS_ALLOWED_HEADERS" default:"Authorization,Content-Type,X-API-Key,X-Auth-Mode,X-CSRF-Token,X-Request-ID"`
+	ExposedHeaders   []string      `key:"exposed_headers" env:"CORS_EXPOSED_HEADERS" default:"X-Request-ID,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After"`
+	AllowCredentials bool          `key:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" default:"false" usage:"allow cookies, needed for cookie sessions from another origin"`
+	MaxAge           time.Duration `key:"max_age" env:"CORS_MAX_AGE" default:"10m" usage:"how long browsers cache preflight results"`
+}
+
+// HeadersConfig overrides the built-in security header policies, see
+// utils.SecurityHeadersConfig, empty values keep the built-in ones
+type HeadersConfig struct {
+	Enabled           bool   `key:"enabled" env:"SECURITY_HEADERS_ENABLED" default:"true"`
+	CSP               string `key:"csp" env:"SECURITY_HEADERS_CSP" usage:"Content-Security-Policy of routes outside of /api, /oauth and /admin"`
+	APICSP            string `key:"api_csp" env:"SECURITY_HEADERS_API_CSP" usage:"Content-Security-Policy of /api routes"`
+	AdminCSP          string `key:"admin_csp" env:"SECURITY_HEADERS_ADMIN_CSP" usage:"Content-Security-Policy of /admin routes"`
+	PermissionsPolicy string `key:"permissions_policy" env:"SECURITY_HEADERS_PERMISSIONS_POLICY"`
+}
+
+// RateLimitConfig sets the per route rates as limit/window, e.g. 10/1m, a
+// limit of 0 disables the route policy
+type RateLimitConfig struct {
+	Enabled       bool   `key:"enabled" env:"RATE_LIMIT_ENABLED" default:"true"`
+	Store         string `key:"store" env:"RATE_LIMIT_STORE" default:"memory" usage:"memory (per instance) or postgres (shared by all instances)"`
+	MemoryMaxKeys int    `key:"memory_max_keys" env:"RATE_LIMIT_MEMORY_MAX_KEYS" default:"100000"`
+	Login         string `key:"login" env:"RATE_LIMIT_LOGIN" default:"10/1m" usage:"login attempts per client ip, sliding window"`
+	Register      string `key:"register" env:"RATE_LIMIT_REGISTER" default:"5/1h" usage:"registrations per client ip, sliding window"`
+	Auth          string `key:"auth" env:"RATE_LIMIT_AUTH" default:"60/1m" usage:"unauthenticated auth and oauth requests per client ip, token bucket"`
+	API           string `key:"api" env:"RATE_LIMIT_API" default:"600/1m" usage:"authenticated requests per API key or user, token bucket"`
+	APIBurst      int    `key:"api_burst" env:"RATE_LIMIT_API_BURST" default:"100"`
+}
+
+type CompressConfig struct {
+	Enabled bool `key:"enabled" env:"COMPRESSION_ENABLED" default:"true" usage:"gzip responses for clients that accept it"`
+	Level   int  `key:"level" env:"COMPRESSION_LEVEL" default:"5" usage:"gzip level, 1 (fastest) to 9 (smallest)"`
+	MinSize int  `key:"min_size" env:"COMPRESSION_MIN_SIZE" default:"1024" usage:"smaller bodies are not compressed"`
+}
+
+type TracingConfig struct {
+	ServiceName    string  `key:"service_name" env:"OTEL_SERVICE_NAME" default:"go-api-starter-kit"`
+	Endpoint       string  `key:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"OTLP/HTTP base url, tracing export is disabled when empty"`
+	TracesEndpoint string  `key:"traces_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" usage:"full traces url, overrides endpoint"`
+	Headers        string  `key:"headers" env:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true" usage:"key1=value1,key2=value2"`
+	SampleRatio    float64 `key:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG" default:"1"`
+}
+
+func (c *Config) IsProduction() bool {
+	return c.App.Env == EnvProduction
+}
+
+// Source returns where the value of key came from: default, file, env or flag
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return "default"
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadFile reads a yaml (.yaml, .yml) or toml (.toml) config file into flat
+// dotted keys like "db.host", list values are joined with commas.
+// Only the subset needed for configuration is supported: nested tables or
+// mappings, scalars, and lists of scalars
+func ReadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		values, err = parseYAML(string(data))
+	case ".toml":
+		values, err = parseTOML(string(data))
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, use .yaml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return values, nil
+}
+
+type yamlLevel struct {
+	indent int
+	key    string
+}
+
+func parseYAML(data string) (map[string]string, error) {
+	values := make(map[string]string)
+	var stack []yamlLevel
+
+	for i, raw := range strings.Split(data, "\n") {
+		lineNo := i + 1
+		line := strings.TrimRight(stripComment(raw), " \r")
+		content := strings.TrimSpace(line)
+		if content == "" || content == "---" {
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", lineNo)
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		// list items may sit at the same indent as their key
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			for len(stack) > 0 && stack[len(stack)-1].indent > indent {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			item, err := yamlScalar(strings.TrimPrefix(content, "-"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			key := stack[len(stack)-1].key
+			values[key] = joinList(values[key], item)
+			continue
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		key, value, ok := strings.Cut(content, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", lineNo)
+		}
+		key, err := yamlScalar(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if len(stack) > 0 {
+			key = stack[len(stack)-1].key + "." + key
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			// nested mapping or block list follows
+			stack = append(stack, yamlLevel{indent: indent, key: key})
+		case strings.HasPrefix(value, "|"), strings.HasPrefix(value, ">"), strings.HasPrefix(value, "&"), strings.HasPrefix(value, "*"):
+			return nil, fmt.Errorf("line %d: block scalars, anchors and aliases are not supported", lineNo)
+		case strings.HasPrefix(value, "["):
+			list, err := parseInlineList(value, yamlScalar)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			values[key] = list
+		default:
+			values[key], err = yamlScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+	}
+	return values, nil
+}
+
+func yamlScalar(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "~" || s == "null":
+		return "", nil
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+func parseTOML(data string) (map[string]string, error) {
+	values := make(map[string]string)
+	table := ""
+
+	for i, raw := range strings.Split(data, "\n") {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripComment(raw))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && !strings.Contains(line, "=") {
+			if strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: arrays of tables are not supported", lineNo)
+			}
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", lineNo)
+			}
+			name, err := tomlKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			table = name
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key, err := tomlKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+		if _, exists := values[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNo, key)
+		}
+
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''") {
+			return nil, fmt.Errorf("line %d: multi-line strings are not supported", lineNo)
+		}
+		if strings.HasPrefix(value, "[") {
+			values[key], err = parseInlineList(value, tomlScalar)
+		} else {
+			values[key], err = tomlScalar(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	return values, nil
+}
+
+// tomlKey normalizes bare, quoted and dotted keys like a."b".c, quoting
+// rules of keys match the yaml ones closely enough to share the parser
+func tomlKey(s string) (string, error) {
+	parts := strings.Split(s, ".")
+	for i, part := range parts {
+		part, err := yamlScalar(part)
+		if err != nil {
+			return "", err
+		}
+		if part == "" {
+			return "", fmt.Errorf("invalid key %q", s)
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func tomlScalar(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	}
+	// numbers may use underscores, e.g. 1_048_576
+	return strings.ReplaceAll(s, "_", ""), nil
+}
+
+// parseInlineList parses ["a", "b"] into "a,b"
+func parseInlineList(s string, scalar func(string) (string, error)) (string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return "", fmt.Errorf("multi-line lists are not supported")
+	}
+	list := ""
+	for _, item := range strings.Split(s[1:len(s)-1], ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		value, err := scalar(item)
+		if err != nil {
+			return "", err
+		}
+		list = joinList(list, value)
+	}
+	return list, nil
+}
+
+func joinList(list, item string) string {
+	if list == "" {
+		return item
+	}
+	return list + "," + item
+}
+
+// stripComment drops a # comment that is outside of quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// value sources, later ones win
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceEnvFile = "env_file" // <ENV>_FILE, e.g. docker secrets
+	SourceFlag    = "flag"
+)
+
+// field is a settable config value described by its struct tags
+type field struct {
+	key    string
+	env    string
+	def    string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// fields walks a config struct, nested structs add their key as prefix
+func fields(v reflect.Value, keyPrefix, envPrefix string) []field {
+	var result []field
+	t := v.Type()
+	for i := range t.NumField() {
+		structField := t.Field(i)
+		key := structField.Tag.Get("key")
+		if key == "" || key == "-" || !structField.IsExported() {
+			continue
+		}
+		if keyPrefix != "" {
+			key = keyPrefix + "." + key
+		}
+
+		if structField.Type.Kind() == reflect.Struct && structField.Type != reflect.TypeOf(time.Duration(0)) {
+			result = append(result, fields(v.Field(i), key, envPrefix)...)
+			continue
+		}
+
+		result = append(result, field{
+			key:    key,
+			env:    envPrefix + structField.Tag.Get("env"),
+			def:    structField.Tag.Get("default"),
+			usage:  structField.Tag.Get("usage"),
+			secret: structField.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return result
+}
+
+// setValue parses s into a field value, lists are separated by commas or spaces
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		list := strings.FieldsFunc(s, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+// flagValue collects a flag so it can be applied after env and file values
+type flagValue struct {
+	field  field
+	values map[string]string
+}
+
+func (fv *flagValue) String() string {
+	return fv.field.def
+}
+
+func (fv *flagValue) Set(s string) error {
+	fv.values[fv.field.key] = s
+	return nil
+}
+
+func (fv *flagValue) IsBoolFlag() bool {
+	return fv.field.value.Kind() == reflect.Bool
+}
+
+// lookupEnv reads name or the file named by name_FILE, empty values count as unset
+func lookupEnv(name string) (string, string, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		if os.Getenv(name) != "" {
+			return "", "", fmt.Errorf("both %s and %s_FILE are set", name, name)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("read %s_FILE: %w", name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), SourceEnvFile, nil
+	}
+	if value := os.Getenv(name); value != "" {
+		return value, SourceEnv, nil
+	}
+	return "", "", nil
+}
+
+type loader struct {
+	file    map[string]string
+	flags   map[string]string
+	sources map[string]string
+}
+
+// set applies the default, file, env and flag values of f in that order
+func (l *loader) set(f field) error {
+	value, source := f.def, SourceDefault
+	if fileValue, ok := l.file[f.key]; ok {
+		value, source = fileValue, SourceFile
+	}
+	envValue, envSource, err := lookupEnv(f.env)
+	if err != nil {
+		return err
+	}
+	if envSource != "" {
+		value, source = envValue, envSource
+	}
+	if flagValue, ok := l.flags[f.key]; ok {
+		value, source = flagValue, SourceFlag
+	}
+
+	if value == "" {
+		if source == SourceDefault {
+			return nil
+		}
+		// an explicit empty value in the file or a flag clears the default
+		f.value.Set(reflect.Zero(f.value.Type()))
+		l.sources[f.key] = source
+		return nil
+	}
+	if err := setValue(f.value, value); err != nil {
+		return fmt.Errorf("invalid %s (%s) value %q: %w", f.key, source, value, err)
+	}
+	if source != SourceDefault {
+		l.sources[f.key] = source
+	}
+	return nil
+}
+
+// Load reads the configuration from defaults, the file given by -config or
+// CONFIG_FILE (yaml or toml), env vars and command line flags. Config flags
+// are added to fs, so commands can register their own flags before calling Load
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := &Config{sources: make(map[string]string)}
+	staticFields := fields(reflect.ValueOf(cfg).Elem(), "", "")
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "yaml or toml config `file`")
+	flagValues := make(map[string]string)
+	for _, f := range staticFields {
+		usage := f.usage
+		if usage == "" {
+			usage = "env " + f.env
+		} else {
+			usage += " (env " + f.env + ")"
+		}
+		fs.Var(&flagValue{field: f, values: flagValues}, f.key, usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	l := &loader{flags: flagValues, sources: cfg.sources}
+	if *configFile != "" {
+		var err error
+		l.file, err = ReadFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	knownKeys := make([]string, 0, len(staticFields))
+	for _, f := range staticFields {
+		knownKeys = append(knownKeys, f.key)
+		errs = append(errs, l.set(f))
+	}
+
+	// provider keys depend on the provider names, they have no flags
+	for _, name := range cfg.OIDC.ProviderNames {
+		provider := OIDCProviderConfig{Name: name}
+		providerFields := fields(
+			reflect.ValueOf(&provider).Elem(),
+			"oidc."+strings.ToLower(name),
+			"OIDC_"+strings.ToUpper(name)+"_",
+		)
+		for _, f := range providerFields {
+			knownKeys = append(knownKeys, f.key)
+			errs = append(errs, l.set(f))
+		}
+		cfg.OIDC.Providers = append(cfg.OIDC.Providers, provider)
+	}
+
+	// a typo in the file would otherwise be silently ignored
+	for key := range l.file {
+		if !slices.Contains(knownKeys, key) {
+			errs = append(errs, fmt.Errorf("unknown config key %q in %s", key, *configFile))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.applyDerivedDefaults(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyDerivedDefaults fills values whose default depends on other values
+func (c *Config) applyDerivedDefaults() error {
+	if c.OAuth.Issuer == "" {
+		c.OAuth.Issuer = "http://localhost:" + c.Server.Port
+	}
+	if c.MagicLink.URL == "" {
+		c.MagicLink.URL = strings.TrimSuffix(c.OAuth.Issuer, "/") + "/api/v1/auth/magic-link/verify"
+	}
+	if len(c.WebAuthn.Origins) == 0 {
+		c.WebAuthn.Origins = []string{"http://localhost:" + c.Server.Port}
+	}
+
+	// production refuses to start without a secret, see Validate
+	if c.Auth.JWTSecret == "" && !c.IsProduction() {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		c.Auth.JWTSecret = base64.RawURLEncoding.EncodeToString(secret)
+		c.sources["auth.jwt_secret"] = "generated"
+		c.Warnings = append(c.Warnings, "JWT_SECRET is not set, using a random secret, tokens are invalidated on restart")
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// load runs Load with a yaml config file and args, env vars are set by the caller
+func load(t *testing.T, yaml string, args ...string) (*Config, error) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	if yaml != "" {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", path}, args...)
+	}
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func TestLoadExplicitEmptyValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		args   []string
+		key    string
+		source string
+		check  func(*Config) bool
+	}{
+		{
+			name:   "empty string in file",
+			yaml:   "db:\n  user: \"\"\n",
+			key:    "db.user",
+			source: SourceFile,
+			check:  func(c *Config) bool { return c.DB.User == "" },
+		},
+		{
+			name:   "null in file",
+			yaml:   "log:\n  level: null\n",
+			key:    "log.level",
+			source: SourceFile,
+			check:  func(c *Config) bool { return c.Log.Level == "" },
+		},
+		{
+			name:   "empty duration flag",
+			args:   []string{"-server.handler_timeout="},
+			key:    "server.handler_timeout",
+			source: SourceFlag,
+			check:  func(c *Config) bool { return c.Server.HandlerTimeout == 0 },
+		},
+		{
+			name:   "empty bool flag",
+			args:   []string{"-server.allow_gzip="},
+			key:    "server.allow_gzip",
+			source: SourceFlag,
+			check:  func(c *Config) bool { return !c.Server.AllowGzip },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := load(t, tt.yaml, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Error("explicit empty value didn't clear the default")
+			}
+			if source := cfg.sources[tt.key]; source != tt.source {
+				t.Errorf("%s source = %q, want %q", tt.key, source, tt.source)
+			}
+		})
+	}
+
+	t.Run("empty env var is unset", func(t *testing.T) {
+		t.Setenv("DB_USER", "")
+		cfg, err := load(t, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DB.User != "postgres" {
+			t.Errorf("db.user = %q, want the default", cfg.DB.User)
+		}
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const yaml = "db:\n  host: file-host\n  port: \"5433\"\n  name: file-name\n"
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		args       []string
+		wantHost   string
+		wantSource string
+	}{
+		{name: "file over default", wantHost: "file-host", wantSource: SourceFile},
+		{name: "env over file", env: map[string]string{"DB_HOST": "env-host"}, wantHost: "env-host", wantSource: SourceEnv},
+		{name: "flag over env", env: map[string]string{"DB_HOST": "env-host"}, args: []string{"-db.host", "flag-host"}, wantHost: "flag-host", wantSource: SourceFlag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_HOST", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := load(t, yaml, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DB.Host != tt.wantHost || cfg.sources["db.host"] != tt.wantSource {
+				t.Errorf("db.host = %q from %q, want %q from %q", cfg.DB.Host, cfg.sources["db.host"], tt.wantHost, tt.wantSource)
+			}
+			// untouched keys keep their file and default values
+			if cfg.DB.Port != "5433" || cfg.DB.User != "postgres" {
+				t.Errorf("db.port = %q, db.user = %q, want 5433 and postgres", cfg.DB.Port, cfg.DB.User)
+			}
+			if _, ok := cfg.sources["db.user"]; ok {
+				t.Error("default value recorded with a source")
+			}
+		})
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "file content without trailing newline", env: map[string]string{"DB_PASSWORD_FILE": secretFile}, want: "s3cret"},
+		{name: "value and file", env: map[string]string{"DB_PASSWORD_FILE": secretFile, "DB_PASSWORD": "other"}, wantErr: true},
+		{name: "missing file", env: map[string]string{"DB_PASSWORD_FILE": secretFile + ".missing"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_PASSWORD", "")
+			t.Setenv("DB_PASSWORD_FILE", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := load(t, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Load succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DB.Password != tt.want || cfg.sources["db.password"] != SourceEnvFile {
+				t.Errorf("db.password = %q from %q, want %q from %q", cfg.DB.Password, cfg.sources["db.password"], tt.want, SourceEnvFile)
+			}
+		})
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		args []string
+	}{
+		{name: "unknown file key", yaml: "db:\n  hots: localhost\n"},
+		{name: "invalid duration", yaml: "server:\n  read_timeout: soon\n"},
+		{name: "invalid bool flag", args: []string{"-db.auto_migrate=maybe"}},
+		{name: "positional argument", args: []string{"extra"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := load(t, tt.yaml, tt.args...); err == nil {
+				t.Fatal("Load succeeded, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const redactedValue = "[REDACTED]"
+
+// Print writes the effective configuration with the source of each value,
+// secret values are redacted
+func Print(w io.Writer, c *Config) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tENV\tVALUE\tSOURCE")
+
+	printFields := func(fields []field) {
+		for _, f := range fields {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.key, f.env, formatValue(f), c.Source(f.key))
+		}
+	}
+
+	printFields(fields(reflect.ValueOf(c).Elem(), "", ""))
+	for i := range c.OIDC.Providers {
+		provider := &c.OIDC.Providers[i]
+		printFields(fields(
+			reflect.ValueOf(provider).Elem(),
+			"oidc."+strings.ToLower(provider.Name),
+			"OIDC_"+strings.ToUpper(provider.Name)+"_",
+		))
+	}
+
+	return tw.Flush()
+}
+
+func formatValue(f field) string {
+	if f.secret {
+		if f.value.IsZero() {
+			return ""
+		}
+		return redactedValue
+	}
+	if f.value.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(f.value.Int()).String()
+	}
+	if f.value.Kind() == reflect.Slice {
+		return strings.Join(f.value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(f.value.Interface())
+}
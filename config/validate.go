@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// minSecretLength is the minimum JWT secret size in bytes, HS256 keys
+// shorter than the hash output weaken the signature
+const minSecretLength = 32
+
+// weakSecrets are placeholder values that must never sign tokens
+var weakSecrets = []string{"secret", "changeme", "change-me", "password", "jwt_secret", "your-secret-key"}
+
+// Validate checks the configuration and returns every problem at once.
+// Production mode additionally rejects missing or weak secrets and settings
+// that are only acceptable in development
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.App.Env == EnvDevelopment || c.App.Env == EnvProduction, "app.env must be %s or %s", EnvDevelopment, EnvProduction)
+
+	// server
+	if c.Server.ListenAddr == "" {
+		port, err := strconv.Atoi(c.Server.Port)
+		check(err == nil && port > 0 && port < 65536, "server.port must be a port number")
+	}
+	check(c.Server.ReadHeaderTimeout >= 0 && c.Server.ReadTimeout >= 0 && c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0, "server timeouts must not be negative")
+	check(c.Server.HandlerTimeout >= 0, "server.handler_timeout must not be negative")
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes must be positive")
+	check(c.Server.MaxBodyBytes > 0, "server.max_body_bytes must be positive")
+	check(c.Server.ShutdownDelay >= 0 && c.Server.ShutdownTimeout > 0, "server.shutdown_delay must not be negative and server.shutdown_timeout must be positive")
+
+	_, err := utils.ParseTrustedProxies(c.Server.TrustedProxies)
+	check(err == nil, "server.trusted_proxies: %v", err)
+
+	// logging
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format must be json or text")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level %q is not a log level", c.Log.Level)
+
+	// auth
+	check(c.Auth.JWTSecret != "", "auth.jwt_secret (JWT_SECRET) is required")
+	if c.Auth.JWTSecret != "" && c.IsProduction() {
+		check(len(c.Auth.JWTSecret) >= minSecretLength, "auth.jwt_secret must be at least %d bytes in production", minSecretLength)
+		for _, weak := range weakSecrets {
+			check(!strings.EqualFold(c.Auth.JWTSecret, weak), "auth.jwt_secret is a well known placeholder value")
+		}
+	}
+	switch strings.ToLower(c.Auth.CookieSameSite) {
+	case "strict", "lax", "none":
+	default:
+		check(false, "auth.cookie_samesite must be strict, lax or none")
+	}
+	if c.Auth.CookieEnabled && strings.EqualFold(c.Auth.CookieSameSite, "none") {
+		check(c.Auth.CookieSecure, "auth.cookie_secure is required with auth.cookie_samesite none")
+	}
+
+	// oauth
+	issuer, err := url.Parse(c.OAuth.Issuer)
+	check(err == nil && issuer.Host != "" && (issuer.Scheme == "http" || issuer.Scheme == "https"), "oauth.issuer must be an absolute http(s) url")
+
+	if c.OAuth.LoginURL != "" {
+		loginURL, err := url.Parse(c.OAuth.LoginURL)
+		check(err == nil && loginURL.Host != "" && (loginURL.Scheme == "http" || loginURL.Scheme == "https"), "oauth.login_url must be an absolute http(s) url")
+	}
+
+	// oidc providers
+	for _, provider := range c.OIDC.Providers {
+		check(provider.Issuer != "" && provider.ClientID != "" && provider.RedirectURL != "",
+			"oidc provider %q needs issuer, client_id and redirect_url", provider.Name)
+	}
+
+	// tls
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	_, err = utils.ParseTLSVersion(c.TLS.MinVersion)
+	check(err == nil, "tls.min_version: %v", err)
+	_, err = utils.ParseCipherSuites(strings.Join(c.TLS.CipherSuites, ","))
+	check(err == nil, "tls.cipher_suites: %v", err)
+	_, err = utils.ParseClientAuth(c.TLS.ClientAuth)
+	check(err == nil, "tls.client_auth: %v", err)
+	if c.TLS.ClientCertAuth {
+		check(c.TLS.ClientCAFile != "", "tls.client_cert_auth requires tls.client_ca_file")
+	}
+	if c.TLS.RedirectAddr != "" {
+		check(c.TLS.CertFile != "", "tls.redirect_addr requires TLS to be enabled")
+	}
+
+	// cors
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(utils.ValidOriginPattern(origin), "cors.allowed_origins: %q is not an origin like https://app.example.com", origin)
+		if origin == "*" {
+			check(!c.CORS.AllowCredentials, "cors.allowed_origins * can't be used with cors.allow_credentials")
+		}
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+
+	// rate limits
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "postgres", "rate_limit.store must be memory or postgres")
+	check(c.RateLimit.MemoryMaxKeys > 0, "rate_limit.memory_max_keys must be positive")
+	check(c.RateLimit.APIBurst >= 0, "rate_limit.api_burst must not be negative")
+	for _, rate := range []struct{ key, value string }{
+		{"login", c.RateLimit.Login},
+		{"register", c.RateLimit.Register},
+		{"auth", c.RateLimit.Auth},
+		{"api", c.RateLimit.API},
+	} {
+		_, _, err := utils.ParseRateLimit(rate.value)
+		check(err == nil, "rate_limit.%s: %v", rate.key, err)
+	}
+
+	// compression
+	check(c.Compress.Level >= 1 && c.Compress.Level <= 9, "compression.level must be between 1 and 9")
+	check(c.Compress.MinSize >= 0, "compression.min_size must not be negative")
+
+	// tracing
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+
+	if c.IsProduction() {
+		check(!c.Auth.CookieEnabled || c.Auth.CookieSecure, "auth.cookie_secure must be enabled in production")
+		check(c.OAuth.SigningKeyFile != "", "oauth.signing_key_file is required in production, an ephemeral key invalidates tokens on restart")
+		check(issuer != nil && issuer.Scheme == "https", "oauth.issuer must use https in production")
+		check(c.DB.Password != "", "db.password is required in production")
+		check(c.SMTP.Host != "", "smtp.host is required in production, emails would not be delivered")
+	}
+
+	return errors.Join(errs...)
+}
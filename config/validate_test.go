@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// productionConfig loads a production config that passes Validate
+func productionConfig(t *testing.T) *Config {
+	t.Helper()
+	for name, value := range map[string]string{
+		"APP_ENV":                "production",
+		"JWT_SECRET":             strings.Repeat("k", minSecretLength),
+		"OAUTH_ISSUER":           "https://auth.example.com",
+		"OAUTH_SIGNING_KEY_FILE": "/run/secrets/oauth_signing_key",
+		"DB_PASSWORD":            "db-password",
+		"SMTP_HOST":              "smtp.example.com",
+	} {
+		t.Setenv(name, value)
+	}
+	cfg, err := load(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("production config is invalid: %v", err)
+	}
+	return cfg
+}
+
+func TestValidateProduction(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"missing jwt secret", func(c *Config) { c.Auth.JWTSecret = "" }, "auth.jwt_secret (JWT_SECRET) is required"},
+		{"short jwt secret", func(c *Config) { c.Auth.JWTSecret = "short" }, "at least 32 bytes"},
+		{"placeholder jwt secret", func(c *Config) { c.Auth.JWTSecret = "changeme" }, "well known placeholder"},
+		{"insecure session cookie", func(c *Config) { c.Auth.CookieEnabled, c.Auth.CookieSecure = true, false }, "auth.cookie_secure must be enabled"},
+		{"ephemeral oauth key", func(c *Config) { c.OAuth.SigningKeyFile = "" }, "oauth.signing_key_file is required"},
+		{"http issuer", func(c *Config) { c.OAuth.Issuer = "http://auth.example.com" }, "oauth.issuer must use https"},
+		{"no db password", func(c *Config) { c.DB.Password = "" }, "db.password is required"},
+		{"no smtp host", func(c *Config) { c.SMTP.Host = "" }, "smtp.host is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := productionConfig(t)
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("development allows them", func(t *testing.T) {
+		cfg := productionConfig(t)
+		cfg.App.Env = EnvDevelopment
+		cfg.Auth.JWTSecret = "changeme"
+		cfg.OAuth.Issuer = "http://localhost:8000"
+		cfg.OAuth.SigningKeyFile, cfg.DB.Password, cfg.SMTP.Host = "", "", ""
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() = %v, want nil in development", err)
+		}
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		cfg := productionConfig(t)
+		cfg.DB.Password, cfg.SMTP.Host = "", ""
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "db.password") || !strings.Contains(err.Error(), "smtp.host") {
+			t.Fatalf("Validate() = %v, want both problems", err)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/config"
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// configure applies a validated config to the packages that hold runtime settings
+func configure(cfg *config.Config) error {
+	// tracing, spans are exported only when an OTLP endpoint is configured
+	tracingConfig := tracing.Config{
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	}
+	otlpEndpoint := cfg.Tracing.TracesEndpoint
+	if otlpEndpoint == "" && cfg.Tracing.Endpoint != "" {
+		otlpEndpoint = strings.TrimSuffix(cfg.Tracing.Endpoint, "/") + "/v1/traces"
+	}
+	if otlpEndpoint != "" {
+		tracingConfig.Exporter = &tracing.OTLPExporter{
+			Endpoint:    otlpEndpoint,
+			Headers:     tracing.ParseOTLPHeaders(cfg.Tracing.Headers),
+			ServiceName: tracingConfig.ServiceName,
+		}
+	}
+	tracing.Setup(tracingConfig)
+
+	utils.SetJWTSecretKey([]byte(cfg.Auth.JWTSecret))
+
+	// oauth2 authorization server
+	utils.SetOAuthIssuer(cfg.OAuth.Issuer)
+	utils.SetOAuthLoginURL(cfg.OAuth.LoginURL)
+	if err := utils.LoadSigningKey(cfg.OAuth.SigningKeyFile); err != nil {
+		return fmt.Errorf("load oauth signing key: %w", err)
+	}
+
+	// mailer, emails are logged when smtp is not configured
+	if cfg.SMTP.Host != "" {
+		utils.SetMailer(&utils.SMTPMailer{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+		})
+	}
+
+	magicLinkConfig := utils.GetMagicLinkConfig()
+	magicLinkConfig.URL = cfg.MagicLink.URL
+	utils.SetMagicLinkConfig(magicLinkConfig)
+
+	// webauthn relying party
+	webAuthnConfig := utils.GetWebAuthnConfig()
+	webAuthnConfig.RPID = cfg.WebAuthn.RPID
+	webAuthnConfig.RPName = cfg.WebAuthn.RPName
+	webAuthnConfig.Origins = cfg.WebAuthn.Origins
+	utils.SetWebAuthnConfig(webAuthnConfig)
+
+	// request body limits
+	utils.SetRequestBodyConfig(utils.RequestBodyConfig{
+		MaxBytes:  cfg.Server.MaxBodyBytes,
+		AllowGzip: cfg.Server.AllowGzip,
+	})
+
+	// optional cookie session mode for browser clients
+	cookieConfig := utils.GetCookieConfig()
+	cookieConfig.Enabled = cfg.Auth.CookieEnabled
+	cookieConfig.Domain = cfg.Auth.CookieDomain
+	cookieConfig.Secure = cfg.Auth.CookieSecure
+	cookieConfig.SameSite = utils.ParseSameSite(cfg.Auth.CookieSameSite)
+	utils.SetCookieConfig(cookieConfig)
+
+	// social login providers
+	for _, provider := range cfg.OIDC.Providers {
+		err := utils.RegisterOIDCProvider(utils.OIDCProviderConfig{
+			Name:         provider.Name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		})
+		if err != nil {
+			return fmt.Errorf("configure oidc provider %s: %w", provider.Name, err)
+		}
+	}
+
+	utils.SetServerConfig(utils.ServerConfig{
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		HandlerTimeout:    cfg.Server.HandlerTimeout,
+	})
+
+	utils.SetCORSConfig(utils.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	})
+
+	// security headers, disabled when a proxy in front of the app sets them
+	var securityHeaders utils.SecurityHeadersConfig
+	if cfg.Headers.Enabled {
+		securityHeaders = utils.SecurityHeadersConfig{
+			Default: utils.DefaultSecurityHeaders(),
+			API:     utils.DefaultAPISecurityHeaders(),
+			OAuth:   utils.DefaultOAuthSecurityHeaders(),
+			Admin:   utils.DefaultAdminSecurityHeaders(),
+		}
+		if cfg.Headers.CSP != "" {
+			securityHeaders.Default.ContentSecurityPolicy = cfg.Headers.CSP
+		}
+		if cfg.Headers.APICSP != "" {
+			securityHeaders.API.ContentSecurityPolicy = cfg.Headers.APICSP
+		}
+		if cfg.Headers.AdminCSP != "" {
+			securityHeaders.Admin.ContentSecurityPolicy = cfg.Headers.AdminCSP
+		}
+		if cfg.Headers.PermissionsPolicy != "" {
+			securityHeaders.Default.PermissionsPolicy = cfg.Headers.PermissionsPolicy
+			securityHeaders.API.PermissionsPolicy = cfg.Headers.PermissionsPolicy
+			securityHeaders.OAuth.PermissionsPolicy = cfg.Headers.PermissionsPolicy
+			securityHeaders.Admin.PermissionsPolicy = cfg.Headers.PermissionsPolicy
+		}
+	}
+	utils.SetSecurityHeadersConfig(securityHeaders)
+
+	// rate limits, rates were checked by config.Validate
+	rateLimits := utils.RateLimitConfig{}
+	if cfg.RateLimit.Enabled {
+		rateLimits.Store = utils.NewMemoryRateLimitStore(cfg.RateLimit.MemoryMaxKeys)
+		if cfg.RateLimit.Store == "postgres" {
+			rateLimits.Store = db.NewPostgresRateLimitStore()
+		}
+		policy := func(name, algorithm, rate string) utils.RateLimitPolicy {
+			limit, window, _ := utils.ParseRateLimit(rate)
+			return utils.RateLimitPolicy{Name: name, Algorithm: algorithm, Limit: limit, Window: window}
+		}
+		rateLimits.Login = policy("login", utils.RateLimitSlidingWindow, cfg.RateLimit.Login)
+		rateLimits.Register = policy("register", utils.RateLimitSlidingWindow, cfg.RateLimit.Register)
+		rateLimits.Auth = policy("auth", utils.RateLimitTokenBucket, cfg.RateLimit.Auth)
+		rateLimits.API = policy("api", utils.RateLimitTokenBucket, cfg.RateLimit.API)
+		rateLimits.API.Burst = cfg.RateLimit.APIBurst
+	}
+	utils.SetRateLimitConfig(rateLimits)
+
+	// client ip resolution behind load balancers, values were checked by config.Validate
+	proxyConfig, err := utils.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	utils.SetProxyConfig(proxyConfig)
+
+	utils.SetCompressionConfig(utils.CompressionConfig{
+		Enabled: cfg.Compress.Enabled,
+		Level:   cfg.Compress.Level,
+		MinSize: cfg.Compress.MinSize,
+	})
+
+	// native TLS, values were checked by config.Validate
+	tlsConfig := utils.GetTLSConfig()
+	tlsConfig.CertFile = cfg.TLS.CertFile
+	tlsConfig.KeyFile = cfg.TLS.KeyFile
+	tlsConfig.ReloadInterval = cfg.TLS.ReloadInterval
+	tlsConfig.ClientCAFile = cfg.TLS.ClientCAFile
+	tlsConfig.ClientCertAuth = cfg.TLS.ClientCertAuth
+	tlsConfig.HSTSMaxAge = cfg.TLS.HSTSMaxAge
+	tlsConfig.HSTSIncludeSubdomains = cfg.TLS.HSTSIncludeSubdomains
+	tlsConfig.HSTSPreload = cfg.TLS.HSTSPreload
+	if tlsConfig.MinVersion, err = utils.ParseTLSVersion(cfg.TLS.MinVersion); err != nil {
+		return err
+	}
+	if tlsConfig.CipherSuites, err = utils.ParseCipherSuites(strings.Join(cfg.TLS.CipherSuites, ",")); err != nil {
+		return err
+	}
+	if tlsConfig.ClientAuth, err = utils.ParseClientAuth(cfg.TLS.ClientAuth); err != nil {
+		return err
+	}
+	utils.SetTLSConfig(tlsConfig)
+
+	return nil
+}
+
+func dbConfig(cfg *config.Config) db.DBConfig {
+	return db.DBConfig{
+		Host:     cfg.DB.Host,
+		Port:     cfg.DB.Port,
+		User:     cfg.DB.User,
+		Password: cfg.DB.Password,
+		DBName:   cfg.DB.Name,
+		SSLMode:  cfg.DB.SSLMode,
+	}
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"` // hash will not be returned
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// splitList converts space separated column value to a slice
+func splitList(value string) []string {
+	items := strings.Fields(value)
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var apiKey APIKey
+	var scopes string
+	err := row.Scan(
+		&apiKey.ID,
+		&apiKey.UserID,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		&apiKey.KeyHash,
+		&scopes,
+		&apiKey.ExpiresAt,
+		&apiKey.LastUsedAt,
+		&apiKey.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey.Scopes = splitList(scopes)
+
+	return &apiKey, nil
+}
+
+func CreateAPIKey(ctx context.Context, userID int64, name, prefix, keyHash string, scopes []string, expiresAt *time.Time) (*APIKey, error) {
+	query := `
+    insert into api_keys
+      (user_id, name, prefix, key_hash, scopes, expires_at)
+    values
+      ($1, $2, $3, $4, $5, $6)
+    returning
+      id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at
+  `
+	row := queryRowContext(ctx, query, userID, name, prefix, keyHash, strings.Join(scopes, " "), expiresAt)
+	return scanAPIKey(row)
+}
+
+func GetAPIKeysByUserID(ctx context.Context, userID int64) ([]APIKey, error) {
+	query := `
+    select
+      id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at
+    from api_keys
+    where
+      user_id = $1
+    order by created_at desc
+  `
+	rows, err := queryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apiKeys := []APIKey{}
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		apiKeys = append(apiKeys, *apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+func GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	query := `
+    select
+      id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at
+    from api_keys
+    where
+      key_hash = $1
+  `
+	return scanAPIKey(queryRowContext(ctx, query, keyHash))
+}
+
+// DeleteAPIKey revokes the key, returns false if the key does not belong to the user
+func DeleteAPIKey(ctx context.Context, userID, id int64) (bool, error) {
+	query := `delete from api_keys where id = $1 and user_id = $2`
+	result, err := execContext(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// TouchAPIKey updates last used time, at most once per minute to avoid a write per request
+func TouchAPIKey(ctx context.Context, id int64) error {
+	query := `
+    update api_keys set
+      last_used_at = current_timestamp
+    where id = $1
+      and (last_used_at is null or last_used_at < current_timestamp - interval '1 minute')
+  `
+	_, err := execContext(ctx, query, id)
+	return err
+}
@@ -37,31 +37,9 @@ func InitDB(config DBConfig) error {
 		return err
 	}
 
-	err = DB.Ping()
-	if err != nil {
-		return err
-	}
-
-	return CreateTables()
+	return DB.Ping()
 }
 
 func Close() error {
 	return DB.Close()
 }
-
-func CreateTables() error {
-	usersTable := `
-  CREATE TABLE IF NOT EXISTS users (
-        id SERIAL PRIMARY KEY,
-        email TEXT NOT NULL UNIQUE,
-        password TEXT NOT NULL,
-        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-        updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-        first_name TEXT,
-        last_name TEXT
-    );
-  `
-
-	_, err := DB.Exec(usersTable)
-	return err
-}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// UserIdentity links a user to an account at an external OIDC provider
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func CreateUserIdentity(ctx context.Context, userID int64, provider, subject, email string) error {
+	query := `
+    insert into user_identities
+      (user_id, provider, subject, email)
+    values
+      ($1, $2, $3, $4)
+  `
+	_, err := execContext(ctx, query, userID, provider, subject, email)
+	return err
+}
+
+func GetUserIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	query := `
+    select
+      id, user_id, provider, subject, email, created_at
+    from user_identities
+    where
+      provider = $1 and subject = $2
+  `
+
+	var identity UserIdentity
+	err := queryRowContext(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// magicLinksSweeper keeps expired links while CountRecentMagicLinks still
+// counts them for throttling
+var magicLinksSweeper = &sweeper{table: "magic_links", query: `delete from magic_links where expires_at < $1 and created_at < $2`}
+
+func CreateMagicLink(ctx context.Context, tokenHash string, userID int64, ip string, expiresAt time.Time) error {
+	query := `
+    insert into magic_links
+      (token_hash, user_id, ip, expires_at)
+    values
+      ($1, $2, $3, $4)
+  `
+	_, err := execContext(ctx, query, tokenHash, userID, ip, expiresAt)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	magicLinksSweeper.sweep(ctx, now, now.Add(-utils.GetMagicLinkConfig().Window))
+	return nil
+}
+
+// CountRecentMagicLinks returns links issued for the user since the given time
+func CountRecentMagicLinks(ctx context.Context, userID int64, since time.Time) (int, error) {
+	query := `
+    select count(*)
+    from magic_links
+    where
+      user_id = $1 and created_at > $2
+  `
+	var count int
+	err := queryRowContext(ctx, query, userID, since).Scan(&count)
+	return count, err
+}
+
+// ConsumeMagicLink marks the link used and returns its user, each link works once
+func ConsumeMagicLink(ctx context.Context, tokenHash string) (int64, error) {
+	query := `
+    update magic_links set
+      used_at = current_timestamp
+    where token_hash = $1
+      and used_at is null
+      and expires_at > current_timestamp
+    returning user_id
+  `
+	var userID int64
+	err := queryRowContext(ctx, query, tokenHash).Scan(&userID)
+	return userID, err
+}
@@ -0,0 +1,311 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// Migration is a versioned schema change, applied once in version order.
+// New changes are appended with the next version, applied ones are never edited.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// the first migrations use IF NOT EXISTS so databases created before
+// versioning was introduced are adopted without changes
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_users",
+		SQL: `
+  CREATE TABLE IF NOT EXISTS users (
+        id SERIAL PRIMARY KEY,
+        email TEXT NOT NULL UNIQUE,
+        password TEXT NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        first_name TEXT,
+        last_name TEXT
+    );
+  `,
+	},
+	{
+		Version: 2,
+		Name:    "create_api_keys",
+		SQL: `
+  CREATE TABLE IF NOT EXISTS api_keys (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        name TEXT NOT NULL,
+        prefix TEXT NOT NULL,
+        key_hash TEXT NOT NULL UNIQUE,
+        scopes TEXT NOT NULL DEFAULT '',
+        expires_at TIMESTAMP WITH TIME ZONE,
+        last_used_at TIMESTAMP WITH TIME ZONE,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+  CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys(user_id);
+  `,
+	},
+	{
+		Version: 3,
+		Name:    "create_user_identities",
+		SQL: `
+  CREATE TABLE IF NOT EXISTS user_identities (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        provider TEXT NOT NULL,
+        subject TEXT NOT NULL,
+        email TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (provider, subject)
+    );
+  `,
+	},
+	{
+		Version: 4,
+		Name:    "create_oauth",
+		SQL: `
+  CREATE TABLE IF NOT EXISTS oauth_clients (
+        id SERIAL PRIMARY KEY,
+        client_id TEXT NOT NULL UNIQUE,
+        client_secret_hash TEXT NOT NULL DEFAULT '',
+        name TEXT NOT NULL,
+        redirect_uris TEXT NOT NULL DEFAULT '',
+        grant_types TEXT NOT NULL DEFAULT '',
+        scopes TEXT NOT NULL DEFAULT '',
+        owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+  CREATE TABLE IF NOT EXISTS oauth_consents (
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+        scopes TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (user_id, client_id)
+    );
+  CREATE TABLE IF NOT EXISTS oauth_codes (
+        code_hash TEXT PRIMARY KEY,
+        client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        redirect_uri TEXT NOT NULL,
+        scopes TEXT NOT NULL DEFAULT '',
+        nonce TEXT NOT NULL DEFAULT '',
+        code_challenge TEXT NOT NULL DEFAULT '',
+        auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+        expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+    );
+  CREATE TABLE IF NOT EXISTS oauth_tokens (
+        token_hash TEXT PRIMARY KEY,
+        token_type TEXT NOT NULL,
+        grant_id TEXT NOT NULL,
+        client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        scopes TEXT NOT NULL DEFAULT '',
+        auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+        expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+  CREATE INDEX IF NOT EXISTS oauth_tokens_grant_id_idx ON oauth_tokens(grant_id);
+  `,
+	},
+	{
+		Version: 5,
+		Name:    "create_magic_links",
+		SQL: `
+  CREATE TABLE IF NOT EXISTS magic_links (
+        token_hash TEXT PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        ip TEXT NOT NULL DEFAULT '',
+        expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+        used_at TIMESTAMP WITH TIME ZONE,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+  CREATE INDEX IF NOT EXISTS magic_links_created_at_idx ON magic_links(created_at);
+  `,
+	},
+	{
+		Version: 6,
+		Name:    "create_webauthn",
+		SQL: `
+  CREATE TABLE IF NOT EXISTS webauthn_credentials (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        credential_id TEXT NOT NULL UNIQUE,
+        public_key BYTEA NOT NULL,
+        sign_count BIGINT NOT NULL DEFAULT 0,
+        aaguid BYTEA,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        last_used_at TIMESTAMP WITH TIME ZONE
+    );
+  CREATE TABLE IF NOT EXISTS webauthn_sessions (
+        challenge TEXT PRIMARY KEY,
+        ceremony TEXT NOT NULL,
+        user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+        expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+    );
+  `,
+	},
+	{
+		Version: 7,
+		Name:    "add_users_is_admin",
+		SQL: `
+  ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+  `,
+	},
+	{
+		Version: 8,
+		Name:    "create_rate_limits",
+		SQL: `
+  CREATE TABLE IF NOT EXISTS rate_limits (
+        key TEXT PRIMARY KEY,
+        value DOUBLE PRECISION NOT NULL DEFAULT 0,
+        previous DOUBLE PRECISION NOT NULL DEFAULT 0,
+        time TIMESTAMP WITH TIME ZONE,
+        expires_at TIMESTAMP WITH TIME ZONE
+    );
+  CREATE INDEX IF NOT EXISTS rate_limits_expires_at_idx ON rate_limits(expires_at);
+  `,
+	},
+	{
+		Version: 9,
+		Name:    "keep_used_oauth_codes",
+		SQL: `
+  ALTER TABLE oauth_codes ADD COLUMN IF NOT EXISTS grant_id TEXT NOT NULL DEFAULT '';
+  ALTER TABLE oauth_codes ADD COLUMN IF NOT EXISTS used_at TIMESTAMP WITH TIME ZONE;
+  CREATE INDEX IF NOT EXISTS oauth_codes_expires_at_idx ON oauth_codes(expires_at);
+  CREATE INDEX IF NOT EXISTS oauth_tokens_expires_at_idx ON oauth_tokens(expires_at);
+  `,
+	},
+	{
+		Version: 10,
+		Name:    "index_webauthn_sessions_expires_at",
+		SQL: `
+  CREATE INDEX IF NOT EXISTS webauthn_sessions_expires_at_idx ON webauthn_sessions(expires_at);
+  `,
+	},
+}
+
+const migrationsTable = `
+  CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+  `
+
+// Migrations returns the known migrations in version order
+func Migrations() []Migration {
+	return migrations
+}
+
+// migrationsLockKey is the postgres advisory lock held while migrating,
+// so replicas starting at the same time don't apply a migration twice
+const migrationsLockKey = 7243019
+
+// querier is implemented by *sql.DB and *sql.Conn
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// AppliedMigrations returns the versions recorded in schema_migrations
+func AppliedMigrations(ctx context.Context) (map[int]bool, error) {
+	return appliedMigrations(ctx, DB)
+}
+
+func appliedMigrations(ctx context.Context, q querier) (map[int]bool, error) {
+	rows, err := q.QueryContext(ctx, `select version from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// PendingMigrations returns migrations not applied yet
+func PendingMigrations(ctx context.Context) ([]Migration, error) {
+	return pendingMigrations(ctx, DB)
+}
+
+func pendingMigrations(ctx context.Context, q querier) ([]Migration, error) {
+	applied, err := appliedMigrations(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies pending migrations, each in its own transaction
+func Migrate(ctx context.Context) error {
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `select pg_advisory_lock($1)`, migrationsLockKey)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `select pg_advisory_unlock($1)`, migrationsLockKey)
+
+	_, err = conn.ExecContext(ctx, migrationsTable)
+	if err != nil {
+		return err
+	}
+
+	pending, err := pendingMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, migration.SQL)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `insert into schema_migrations (version, name) values ($1, $2)`, migration.Version, migration.Name)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "migration applied", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+// Ping checks the database connection
+func Ping(ctx context.Context) error {
+	return DB.PingContext(ctx)
+}
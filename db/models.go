@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"time"
 )
 
@@ -12,9 +13,10 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
+	IsAdmin   bool      `json:"is_admin"`
 }
 
-func CreateUser(email, password, first_name, last_name string) (*User, error) {
+func CreateUser(ctx context.Context, email, password, first_name, last_name string) (*User, error) {
 	var user User
 	query := `
     insert into users 
@@ -22,9 +24,9 @@ func CreateUser(email, password, first_name, last_name string) (*User, error) {
     values 
       ($1, $2, $3, $4)
     returning
-      id, email, password, first_name, last_name, created_at, updated_at
+      id, email, password, first_name, last_name, created_at, updated_at, is_admin
   `
-	err := DB.QueryRow(query, email, password, first_name, last_name).Scan(
+	err := queryRowContext(ctx, query, email, password, first_name, last_name).Scan(
 		&user.ID,
 		&user.Email,
 		&user.Password,
@@ -32,49 +34,60 @@ func CreateUser(email, password, first_name, last_name string) (*User, error) {
 		&user.LastName,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.IsAdmin,
 	)
 	return &user, err
 }
 
-func UpdateUser(userID int64, first_name, last_name string) error {
+func UpdateUser(ctx context.Context, userID int64, first_name, last_name string) error {
 	query := `
     update users set 
       first_name = $1, last_name = $2, updated_at = current_timestamp
     where id = $3
   `
-	_, err := DB.Exec(query, first_name, last_name, userID)
+	_, err := execContext(ctx, query, first_name, last_name, userID)
 	return err
 
 }
 
-func ChangeUserPassword(userID int64, hashedPassword string) error {
+func ChangeUserPassword(ctx context.Context, userID int64, hashedPassword string) error {
 	query := `
     update users set 
       password = $1, updated_at = current_timestamp
     where id = $2
   `
-	_, err := DB.Exec(query, hashedPassword, userID)
+	_, err := execContext(ctx, query, hashedPassword, userID)
 	return err
 }
 
-func CheckUserExistsByEmail(email string) (bool, error) {
+func SetUserAdmin(ctx context.Context, userID int64, isAdmin bool) error {
+	query := `
+    update users set 
+      is_admin = $1, updated_at = current_timestamp
+    where id = $2
+  `
+	_, err := execContext(ctx, query, isAdmin, userID)
+	return err
+}
+
+func CheckUserExistsByEmail(ctx context.Context, email string) (bool, error) {
 	query := `select exists(select 1 from users where email = $1)`
 	var exists bool
-	err := DB.QueryRow(query, email).Scan(&exists)
+	err := queryRowContext(ctx, query, email).Scan(&exists)
 	return exists, err
 }
 
-func GetUserByEmail(email string) (*User, error) {
+func GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
     select 
-      id, email, password, first_name, last_name, created_at, updated_at
+      id, email, password, first_name, last_name, created_at, updated_at, is_admin
     from users
     where 
       email = $1
   `
 
 	var user User
-	err := DB.QueryRow(query, email).Scan(
+	err := queryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.Email,
 		&user.Password,
@@ -82,6 +95,7 @@ func GetUserByEmail(email string) (*User, error) {
 		&user.LastName,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.IsAdmin,
 	)
 	if err != nil {
 		return nil, err
@@ -90,17 +104,17 @@ func GetUserByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
-func GetUserByID(id int64) (*User, error) {
+func GetUserByID(ctx context.Context, id int64) (*User, error) {
 	query := `
     select 
-      id, email, password, first_name, last_name, created_at, updated_at
+      id, email, password, first_name, last_name, created_at, updated_at, is_admin
     from users
     where 
       id = $1
   `
 
 	var user User
-	err := DB.QueryRow(query, id).Scan(
+	err := queryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Email,
 		&user.Password,
@@ -108,6 +122,7 @@ func GetUserByID(id int64) (*User, error) {
 		&user.LastName,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.IsAdmin,
 	)
 	if err != nil {
 		return nil, err
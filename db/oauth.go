@@ -0,0 +1,336 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type OAuthClient struct {
+	ID               int64     `json:"-"`
+	ClientID         string    `json:"client_id"`
+	ClientSecretHash string    `json:"-"` // hash will not be returned
+	Name             string    `json:"name"`
+	RedirectURIs     []string  `json:"redirect_uris"`
+	GrantTypes       []string  `json:"grant_types"`
+	Scopes           []string  `json:"scopes"`
+	OwnerID          int64     `json:"owner_id"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Confidential clients authenticate with a secret, public clients rely on PKCE only
+func (c *OAuthClient) Confidential() bool {
+	return c.ClientSecretHash != ""
+}
+
+type OAuthCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        int64
+	RedirectURI   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+}
+
+type OAuthToken struct {
+	TokenHash string
+	TokenType string
+	GrantID   string // tokens issued from the same authorization share a grant id
+	ClientID  string
+	UserID    *int64 // nil for client credentials grant
+	Scopes    []string
+	AuthTime  time.Time
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+func scanOAuthClient(row rowScanner) (*OAuthClient, error) {
+	var client OAuthClient
+	var redirectURIs, grantTypes, scopes string
+	err := row.Scan(
+		&client.ID,
+		&client.ClientID,
+		&client.ClientSecretHash,
+		&client.Name,
+		&redirectURIs,
+		&grantTypes,
+		&scopes,
+		&client.OwnerID,
+		&client.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	client.RedirectURIs = splitList(redirectURIs)
+	client.GrantTypes = splitList(grantTypes)
+	client.Scopes = splitList(scopes)
+	return &client, nil
+}
+
+func CreateOAuthClient(ctx context.Context, client *OAuthClient) (*OAuthClient, error) {
+	query := `
+    insert into oauth_clients
+      (client_id, client_secret_hash, name, redirect_uris, grant_types, scopes, owner_id)
+    values
+      ($1, $2, $3, $4, $5, $6, $7)
+    returning
+      id, client_id, client_secret_hash, name, redirect_uris, grant_types, scopes, owner_id, created_at
+  `
+	row := queryRowContext(
+		ctx,
+		query,
+		client.ClientID,
+		client.ClientSecretHash,
+		client.Name,
+		strings.Join(client.RedirectURIs, " "),
+		strings.Join(client.GrantTypes, " "),
+		strings.Join(client.Scopes, " "),
+		client.OwnerID,
+	)
+	return scanOAuthClient(row)
+}
+
+func GetOAuthClient(ctx context.Context, clientID string) (*OAuthClient, error) {
+	query := `
+    select
+      id, client_id, client_secret_hash, name, redirect_uris, grant_types, scopes, owner_id, created_at
+    from oauth_clients
+    where
+      client_id = $1
+  `
+	return scanOAuthClient(queryRowContext(ctx, query, clientID))
+}
+
+func GetOAuthClientsByOwner(ctx context.Context, ownerID int64) ([]OAuthClient, error) {
+	query := `
+    select
+      id, client_id, client_secret_hash, name, redirect_uris, grant_types, scopes, owner_id, created_at
+    from oauth_clients
+    where
+      owner_id = $1
+    order by created_at desc
+  `
+	rows, err := queryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, *client)
+	}
+
+	return clients, rows.Err()
+}
+
+// DeleteOAuthClient removes the client with all its codes, tokens and consents
+func DeleteOAuthClient(ctx context.Context, ownerID int64, clientID string) (bool, error) {
+	query := `delete from oauth_clients where client_id = $1 and owner_id = $2`
+	result, err := execContext(ctx, query, clientID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetOAuthConsent returns scopes the user already granted to the client
+func GetOAuthConsent(ctx context.Context, userID int64, clientID string) ([]string, error) {
+	query := `select scopes from oauth_consents where user_id = $1 and client_id = $2`
+	var scopes string
+	err := queryRowContext(ctx, query, userID, clientID).Scan(&scopes)
+	if err == sql.ErrNoRows {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return splitList(scopes), nil
+}
+
+func SaveOAuthConsent(ctx context.Context, userID int64, clientID string, scopes []string) error {
+	query := `
+    insert into oauth_consents
+      (user_id, client_id, scopes)
+    values
+      ($1, $2, $3)
+    on conflict (user_id, client_id) do update set
+      scopes = excluded.scopes, updated_at = current_timestamp
+  `
+	_, err := execContext(ctx, query, userID, clientID, strings.Join(scopes, " "))
+	return err
+}
+
+var (
+	oauthCodesSweeper  = &sweeper{table: "oauth_codes", query: `delete from oauth_codes where expires_at < $1`}
+	oauthTokensSweeper = &sweeper{table: "oauth_tokens", query: `delete from oauth_tokens where expires_at < $1`}
+)
+
+func CreateOAuthCode(ctx context.Context, code *OAuthCode) error {
+	query := `
+    insert into oauth_codes
+      (code_hash, client_id, user_id, redirect_uri, scopes, nonce, code_challenge, auth_time, expires_at)
+    values
+      ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+  `
+	_, err := execContext(
+		ctx,
+		query,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		strings.Join(code.Scopes, " "),
+		code.Nonce,
+		code.CodeChallenge,
+		code.AuthTime,
+		code.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	oauthCodesSweeper.sweep(ctx, time.Now())
+	return nil
+}
+
+// ConsumeOAuthCode marks the code of the client as used and returns it, so it
+// can be exchanged only once. The row is kept until it expires with the grant
+// id of the issued tokens, a replayed code revokes them with RevokeOAuthCodeGrant.
+// Codes of other clients are left untouched
+func ConsumeOAuthCode(ctx context.Context, codeHash, clientID, grantID string) (*OAuthCode, error) {
+	query := `
+    update oauth_codes set
+      used_at = current_timestamp,
+      grant_id = $3
+    where code_hash = $1 and client_id = $2 and used_at is null
+    returning
+      code_hash, client_id, user_id, redirect_uri, scopes, nonce, code_challenge, auth_time, expires_at
+  `
+
+	var code OAuthCode
+	var scopes string
+	err := queryRowContext(ctx, query, codeHash, clientID, grantID).Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&scopes,
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.AuthTime,
+		&code.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	code.Scopes = splitList(scopes)
+	return &code, nil
+}
+
+// RevokeOAuthCodeGrant deletes the tokens issued from a code that was already
+// used by the client, returns false when the code was never exchanged
+func RevokeOAuthCodeGrant(ctx context.Context, codeHash, clientID string) (bool, error) {
+	var grantID string
+	query := `select grant_id from oauth_codes where code_hash = $1 and client_id = $2 and used_at is not null`
+	err := queryRowContext(ctx, query, codeHash, clientID).Scan(&grantID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, DeleteOAuthGrant(ctx, grantID)
+}
+
+func CreateOAuthToken(ctx context.Context, token *OAuthToken) error {
+	query := `
+    insert into oauth_tokens
+      (token_hash, token_type, grant_id, client_id, user_id, scopes, auth_time, expires_at)
+    values
+      ($1, $2, $3, $4, $5, $6, $7, $8)
+  `
+	_, err := execContext(
+		ctx,
+		query,
+		token.TokenHash,
+		token.TokenType,
+		token.GrantID,
+		token.ClientID,
+		token.UserID,
+		strings.Join(token.Scopes, " "),
+		token.AuthTime,
+		token.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	oauthTokensSweeper.sweep(ctx, time.Now())
+	return nil
+}
+
+func scanOAuthToken(row rowScanner) (*OAuthToken, error) {
+	var token OAuthToken
+	var scopes string
+	err := row.Scan(
+		&token.TokenHash,
+		&token.TokenType,
+		&token.GrantID,
+		&token.ClientID,
+		&token.UserID,
+		&scopes,
+		&token.AuthTime,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = splitList(scopes)
+	return &token, nil
+}
+
+// GetOAuthToken returns the token if it exists and has not expired
+func GetOAuthToken(ctx context.Context, tokenHash string) (*OAuthToken, error) {
+	query := `
+    select
+      token_hash, token_type, grant_id, client_id, user_id, scopes, auth_time, expires_at, created_at
+    from oauth_tokens
+    where
+      token_hash = $1 and expires_at > current_timestamp
+  `
+	return scanOAuthToken(queryRowContext(ctx, query, tokenHash))
+}
+
+// ConsumeOAuthRefreshToken deletes and returns the refresh token for rotation
+func ConsumeOAuthRefreshToken(ctx context.Context, tokenHash string) (*OAuthToken, error) {
+	query := `
+    delete from oauth_tokens
+    where token_hash = $1 and token_type = 'refresh_token'
+    returning
+      token_hash, token_type, grant_id, client_id, user_id, scopes, auth_time, expires_at, created_at
+  `
+	return scanOAuthToken(queryRowContext(ctx, query, tokenHash))
+}
+
+// DeleteOAuthGrant revokes every token issued from the same authorization
+func DeleteOAuthGrant(ctx context.Context, grantID string) error {
+	_, err := execContext(ctx, `delete from oauth_tokens where grant_id = $1`, grantID)
+	return err
+}
+
+func DeleteOAuthToken(ctx context.Context, tokenHash string) error {
+	_, err := execContext(ctx, `delete from oauth_tokens where token_hash = $1`, tokenHash)
+	return err
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// PostgresRateLimitStore keeps rate limit states in the rate_limits table so
+// all instances share limits. Each Take locks the row of its key for the
+// duration of a short transaction
+type PostgresRateLimitStore struct {
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresRateLimitStore() *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{}
+}
+
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, policy utils.RateLimitPolicy, now time.Time) (utils.RateLimitResult, error) {
+	ctx, span := tracing.Start(ctx, "db.TakeRateLimit",
+		tracing.WithSpanKind(tracing.SpanKindClient),
+		tracing.WithAttributes(tracing.String("db.system", "postgresql")),
+	)
+	defer span.End()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		span.RecordError(err)
+		return utils.RateLimitResult{}, err
+	}
+	defer tx.Rollback()
+
+	// the upsert creates or locks the row, concurrent requests of the key wait here
+	query := `
+    insert into rate_limits (key) values ($1)
+    on conflict (key) do update set key = excluded.key
+    returning value, previous, time, expires_at
+  `
+	var state utils.RateLimitState
+	var stateTime, expiresAt sql.NullTime
+	err = tx.QueryRowContext(ctx, query, key).Scan(&state.Value, &state.Previous, &stateTime, &expiresAt)
+	if err != nil {
+		span.RecordError(err)
+		return utils.RateLimitResult{}, err
+	}
+	if expiresAt.Valid && now.Before(expiresAt.Time) {
+		state.Time = stateTime.Time
+		state.ExpiresAt = expiresAt.Time
+	} else {
+		state = utils.RateLimitState{}
+	}
+
+	state, result := policy.Take(state, now)
+
+	query = `
+    update rate_limits set
+      value = $2,
+      previous = $3,
+      time = $4,
+      expires_at = $5
+    where key = $1
+  `
+	_, err = tx.ExecContext(ctx, query, key, state.Value, state.Previous, state.Time, state.ExpiresAt)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.RecordError(err)
+		return utils.RateLimitResult{}, err
+	}
+
+	s.sweep(ctx, now)
+	return result, nil
+}
+
+// sweep deletes expired states about once a minute per instance
+func (s *PostgresRateLimitStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	_, err := execContext(ctx, `delete from rate_limits where expires_at < $1`, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "delete expired rate limits failed", "error", err)
+	}
+}
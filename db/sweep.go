@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// sweeper deletes expired rows of a table about once a minute per instance.
+// It runs on the insert path like the rate limit sweep, so tables filled by
+// unauthenticated requests can't grow without bound
+type sweeper struct {
+	table string
+	query string // deletes rows expired before $1, args of sweep follow
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func (s *sweeper) sweep(ctx context.Context, now time.Time, args ...any) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	_, err := execContext(ctx, s.query, append([]any{now}, args...)...)
+	if err != nil {
+		slog.ErrorContext(ctx, "delete expired rows failed", "table", s.table, "error", err)
+	}
+}
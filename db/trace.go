@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"strings"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
+)
+
+// startQuerySpan names the span after the calling db function, e.g. "db.GetUserByEmail"
+func startQuerySpan(ctx context.Context, query string) (context.Context, *tracing.Span) {
+	name := "db.query"
+	if pc, _, _, ok := runtime.Caller(2); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			name = "db." + fn.Name()[strings.LastIndex(fn.Name(), ".")+1:]
+		}
+	}
+
+	statement := strings.Join(strings.Fields(query), " ")
+	operation, _, _ := strings.Cut(statement, " ")
+	return tracing.Start(ctx, name,
+		tracing.WithSpanKind(tracing.SpanKindClient),
+		tracing.WithAttributes(
+			tracing.String("db.system", "postgresql"),
+			tracing.String("db.operation.name", strings.ToUpper(operation)),
+			// only the parameterized statement, argument values are never recorded
+			tracing.String("db.query.text", statement),
+		),
+	)
+}
+
+func queryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	row := DB.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+	}
+	return row
+}
+
+func queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	rows, err := DB.QueryContext(ctx, query, args...)
+	span.RecordError(err)
+	return rows, err
+}
+
+func execContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	result, err := DB.ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return result, err
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+type WebAuthnCredential struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	CredentialID string     `json:"credential_id"` // base64url encoded
+	PublicKey    []byte     `json:"-"`             // COSE encoded
+	SignCount    int64      `json:"sign_count"`
+	AAGUID       []byte     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+func scanWebAuthnCredential(row rowScanner) (*WebAuthnCredential, error) {
+	var credential WebAuthnCredential
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.CredentialID,
+		&credential.PublicKey,
+		&credential.SignCount,
+		&credential.AAGUID,
+		&credential.CreatedAt,
+		&credential.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func CreateWebAuthnCredential(ctx context.Context, userID int64, credentialID string, publicKey []byte, signCount int64, aaguid []byte) (*WebAuthnCredential, error) {
+	query := `
+    insert into webauthn_credentials
+      (user_id, credential_id, public_key, sign_count, aaguid)
+    values
+      ($1, $2, $3, $4, $5)
+    returning
+      id, user_id, credential_id, public_key, sign_count, aaguid, created_at, last_used_at
+  `
+	return scanWebAuthnCredential(queryRowContext(ctx, query, userID, credentialID, publicKey, signCount, aaguid))
+}
+
+func GetWebAuthnCredential(ctx context.Context, credentialID string) (*WebAuthnCredential, error) {
+	query := `
+    select
+      id, user_id, credential_id, public_key, sign_count, aaguid, created_at, last_used_at
+    from webauthn_credentials
+    where
+      credential_id = $1
+  `
+	return scanWebAuthnCredential(queryRowContext(ctx, query, credentialID))
+}
+
+func GetWebAuthnCredentialsByUserID(ctx context.Context, userID int64) ([]WebAuthnCredential, error) {
+	query := `
+    select
+      id, user_id, credential_id, public_key, sign_count, aaguid, created_at, last_used_at
+    from webauthn_credentials
+    where
+      user_id = $1
+    order by created_at
+  `
+	rows, err := queryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, *credential)
+	}
+
+	return credentials, rows.Err()
+}
+
+// UpdateWebAuthnSignCount stores the new counter, the update is conditional
+// so concurrent assertions with the same counter can't both succeed
+func UpdateWebAuthnSignCount(ctx context.Context, id int64, oldSignCount, newSignCount int64) (bool, error) {
+	query := `
+    update webauthn_credentials set
+      sign_count = $1, last_used_at = current_timestamp
+    where id = $2 and sign_count = $3
+  `
+	result, err := execContext(ctx, query, newSignCount, id, oldSignCount)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// webauthnSessionsSweeper deletes challenges of ceremonies that were never finished
+var webauthnSessionsSweeper = &sweeper{table: "webauthn_sessions", query: `delete from webauthn_sessions where expires_at < $1`}
+
+// CreateWebAuthnSession stores the ceremony challenge, userID is nil for discoverable login
+func CreateWebAuthnSession(ctx context.Context, challenge, ceremony string, userID *int64, expiresAt time.Time) error {
+	query := `
+    insert into webauthn_sessions
+      (challenge, ceremony, user_id, expires_at)
+    values
+      ($1, $2, $3, $4)
+  `
+	_, err := execContext(ctx, query, challenge, ceremony, userID, expiresAt)
+	if err != nil {
+		return err
+	}
+	webauthnSessionsSweeper.sweep(ctx, time.Now())
+	return nil
+}
+
+// ConsumeWebAuthnSession deletes the challenge so it can be used once and returns its user
+func ConsumeWebAuthnSession(ctx context.Context, challenge, ceremony string) (*int64, error) {
+	query := `
+    delete from webauthn_sessions
+    where challenge = $1 and ceremony = $2 and expires_at > current_timestamp
+    returning user_id
+  `
+	var userID *int64
+	err := queryRowContext(ctx, query, challenge, ceremony).Scan(&userID)
+	return userID, err
+}
@@ -6,6 +6,8 @@ require golang.org/x/crypto v0.36.0
 
 require github.com/joho/godotenv v1.5.1
 
+require golang.org/x/term v0.30.0
+
 require (
 	github.com/lib/pq v1.10.9
 	golang.org/x/sys v0.31.0 // indirect
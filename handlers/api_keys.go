@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/middleware"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+	"github.com/olksndrdevhub/go-api-starter-kit/validation"
+)
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"api_key_scopes"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"min=0,max=3650"` // 0 means the key never expires
+}
+
+type CreateAPIKeyResponse struct {
+	db.APIKey
+	Key string `json:"key"` // shown only once
+}
+
+func ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	apiKeys, err := db.GetAPIKeysByUserID(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list api keys failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, apiKeys)
+}
+
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := validation.Validate(req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		expiration := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &expiration
+	}
+
+	// generate key, only the hash is stored
+	key, prefix, keyHash, err := utils.GenerateAPIKey()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate api key failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	apiKey, err := db.CreateAPIKey(r.Context(), userID, strings.TrimSpace(req.Name), prefix, keyHash, req.Scopes, expiresAt)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create api key failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := CreateAPIKeyResponse{
+		APIKey: *apiKey,
+		Key:    key,
+	}
+
+	utils.WriteJson(w, http.StatusCreated, response)
+}
+
+func DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid API key id")
+		return
+	}
+
+	deleted, err := db.DeleteAPIKey(r.Context(), userID, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete api key failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+	if !deleted {
+		utils.WriteProblem(w, r, http.StatusNotFound, utils.ErrCodeNotFound, "API key not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,144 +1,143 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
 
 	"github.com/olksndrdevhub/go-api-starter-kit/db"
 	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+	"github.com/olksndrdevhub/go-api-starter-kit/validation"
 )
 
 type RegisterRequest struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
+	FirstName string `json:"first_name" validate:"required,max=100"`
+	LastName  string `json:"last_name" validate:"required,max=100"`
+	Email     string `json:"email" validate:"required,email,max=254"`
+	Password  string `json:"password" validate:"required,password"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
 
 type AuthResponse struct {
-	Token   string `json:"token,omitempty"`
-	Message string `json:"message,omitempty"`
-	UserId  int64  `json:"user_id,omitempty"`
+	Token     string `json:"token,omitempty"`
+	CSRFToken string `json:"csrf_token,omitempty"`
+	Message   string `json:"message,omitempty"`
+	UserId    int64  `json:"user_id,omitempty"`
 }
 
 func Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if strings.TrimSpace(req.FirstName) == "" || strings.TrimSpace(req.LastName) == "" {
-		http.Error(w, "First name and last name are required", http.StatusBadRequest)
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	if strings.TrimSpace(req.Email) == "" || strings.TrimSpace(req.Password) == "" {
-		http.Error(w, "Email and password are required", http.StatusBadRequest)
+	if err := validation.Validate(req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	// check if user already exists
-	exists, err := db.CheckUserExistsByEmail(req.Email)
+	exists, err := db.CheckUserExistsByEmail(r.Context(), req.Email)
 	if err != nil {
-		http.Error(w, "Error checking user existence", http.StatusConflict)
+		slog.ErrorContext(r.Context(), "check user exists failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Error checking user existence")
 		return
 	}
 	if exists {
-		http.Error(w, "User with this email already exists", http.StatusConflict)
-		return
-	}
-
-	// check password valid
-	if err = utils.ValidatePassword(req.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusConflict, utils.ErrCodeConflict, "User with this email already exists")
 		return
 	}
 
 	// hash password
-	hashedPass, err := utils.HashPassword(req.Password)
+	hashedPass, err := utils.HashPassword(r.Context(), req.Password)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		http.Error(w, "Error processing registration", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "hash password failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Error processing registration")
 		return
 	}
 
 	// create user
-	user, err := db.CreateUser(req.Email, hashedPass, req.FirstName, req.LastName)
-	if err != nil {
-		log.Printf("ERROR: %v", err)
-		http.Error(w, "Error creationg user", http.StatusInternalServerError)
-		return
-	}
-
-	// generate JWT token
-	token, err := utils.GenerateJWTToken(user.ID, req.Email)
+	user, err := db.CreateUser(r.Context(), req.Email, hashedPass, req.FirstName, req.LastName)
 	if err != nil {
-		http.Error(w, "Error generating JWT token", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "create user failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Error creating user")
 		return
 	}
 
 	// return success resp with token
-	response := AuthResponse{
-		Token:   token,
-		Message: "Registration successful",
-		UserId:  user.ID,
-	}
-
-	utils.WriteJson(w, http.StatusCreated, response)
+	writeAuthResponse(w, r, user, utils.WantsCookieSession(r), "Registration successful", http.StatusCreated)
 }
 
 func Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	if strings.TrimSpace(req.Email) == "" || strings.TrimSpace(req.Password) == "" {
-		http.Error(w, "Email and password are required", http.StatusBadRequest)
+	if err := validation.Validate(req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	user, err := db.GetUserByEmail(req.Email)
+	user, err := db.GetUserByEmail(r.Context(), req.Email)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		http.Error(w, "Invalid credentials: user not found", http.StatusUnauthorized)
+		slog.ErrorContext(r.Context(), "get user by email failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: user not found")
 		return
 	}
 
-	match, err := utils.VerifyPassword(req.Password, user.Password)
+	match, err := utils.VerifyPassword(r.Context(), req.Password, user.Password)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "verify password failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
 		return
 	}
 	if !match {
-		http.Error(w, "Invalid credentials: wrong password", http.StatusUnauthorized)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: wrong password")
 		return
 	}
 
+	writeAuthResponse(w, r, user, utils.WantsCookieSession(r), "Login successful", http.StatusOK)
+}
+
+// writeAuthResponse issues a JWT token for the user, for cookie sessions it is set
+// as session cookie instead of returned in the body
+func writeAuthResponse(w http.ResponseWriter, r *http.Request, user *db.User, cookieSession bool, message string, statusCode int) {
 	token, err := utils.GenerateJWTToken(user.ID, user.Email)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		http.Error(w, "Error generating JWT token", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "generate JWT token failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Error generating JWT token")
 		return
 	}
 
 	response := AuthResponse{
 		Token:   token,
-		Message: "Login successful",
+		Message: message,
 		UserId:  user.ID,
 	}
 
-	utils.WriteJson(w, http.StatusOK, response)
+	// set session cookies for browser clients, the token stays in the HttpOnly
+	// cookie so page scripts can't read it from the body
+	if cookieSession {
+		response.CSRFToken, err = utils.SetAuthCookies(w, token)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "set auth cookies failed", "error", err)
+			utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Error creating session")
+			return
+		}
+		response.Token = ""
+	}
+
+	utils.WriteJson(w, statusCode, response)
+}
+
+// Logout clears the session cookies, bearer tokens are stateless and simply dropped by the client
+func Logout(w http.ResponseWriter, r *http.Request) {
+	utils.ClearAuthCookies(w)
+	utils.WriteJson(w, http.StatusOK, AuthResponse{Message: "Logout successful"})
 }
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+func TestWriteAuthResponse(t *testing.T) {
+	utils.SetJWTSecretKey([]byte("test-secret-key-of-at-least-32-bytes"))
+	previous := utils.GetCookieConfig()
+	t.Cleanup(func() { utils.SetCookieConfig(previous) })
+
+	tests := []struct {
+		name          string
+		cookieEnabled bool
+		target        string
+		authMode      string
+		wantCookie    bool
+	}{
+		{name: "bearer client, cookie mode disabled", target: "/v1/auth/login"},
+		{name: "cookie requested, cookie mode disabled", target: "/v1/auth/login", authMode: "cookie"},
+		{name: "bearer client, cookie mode enabled", cookieEnabled: true, target: "/v1/auth/login"},
+		{name: "bearer mode header", cookieEnabled: true, target: "/v1/auth/login", authMode: "bearer"},
+		{name: "cookie mode header", cookieEnabled: true, target: "/v1/auth/login", authMode: "Cookie", wantCookie: true},
+		{name: "cookie mode param", cookieEnabled: true, target: "/v1/auth/login?cookie=true", wantCookie: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := previous
+			config.Enabled = tt.cookieEnabled
+			utils.SetCookieConfig(config)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			if tt.authMode != "" {
+				r.Header.Set(utils.AuthModeHeader, tt.authMode)
+			}
+			writeAuthResponse(w, r, &db.User{ID: 7, Email: "user@example.com"}, utils.WantsCookieSession(r), "Login successful", http.StatusOK)
+
+			var response AuthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			cookies := map[string]*http.Cookie{}
+			for _, cookie := range w.Result().Cookies() {
+				cookies[cookie.Name] = cookie
+			}
+
+			if !tt.wantCookie {
+				if response.Token == "" || response.CSRFToken != "" || len(cookies) != 0 {
+					t.Errorf("token = %q, csrf_token = %q, cookies = %v, want the token in the body only", response.Token, response.CSRFToken, cookies)
+				}
+				return
+			}
+			if response.Token != "" {
+				t.Errorf("body has the token %q", response.Token)
+			}
+			session, ok := cookies[config.Name]
+			if !ok || session.Value == "" || !session.HttpOnly {
+				t.Errorf("session cookie = %+v, want HttpOnly cookie with the token", session)
+			}
+			if csrf, ok := cookies[config.CSRFName]; !ok || csrf.Value != response.CSRFToken {
+				t.Errorf("csrf cookie = %+v, body csrf_token = %q", csrf, response.CSRFToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/middleware"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+	"github.com/olksndrdevhub/go-api-starter-kit/validation"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+type MagicLinkVerifyRequest struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
+
+// the verify page only submits the token, so mail scanners prefetching
+// the link with GET can't consume it. It is a browser, so it asks for a
+// cookie session
+var magicLinkTemplate = template.Must(template.New("magic-link").Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+  <form method="post">
+    <input type="hidden" name="token" value="{{.}}">
+    <input type="hidden" name="cookie" value="true">
+    <button type="submit">Sign in</button>
+  </form>
+</body>
+</html>`))
+
+// magicLinkSendTimeout bounds creating and mailing a link after the response
+const magicLinkSendTimeout = time.Minute
+
+// magicLinkIPStore counts requests per ip when rate limiting is disabled
+var magicLinkIPStore = utils.NewMemoryRateLimitStore(10_000)
+
+// RequestMagicLink emails a single use login link, the response is the same
+// whether the user exists or not
+func RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req MagicLinkRequest
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	if err := validation.Validate(req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	response := map[string]string{"message": "if the account exists, a login link has been sent"}
+	config := utils.GetMagicLinkConfig()
+	clientIP := middleware.GetClientIP(r)
+
+	// throttle per ip before the lookup, so unknown emails count as well and
+	// a 429 does not tell existing accounts apart
+	store := utils.GetRateLimitConfig().Store
+	if store == nil {
+		store = magicLinkIPStore
+	}
+	policy := utils.RateLimitPolicy{Name: "magic_link", Algorithm: utils.RateLimitSlidingWindow, Limit: config.MaxPerIP, Window: config.Window}
+	result, err := store.Take(r.Context(), policy.Name+":ip:"+clientIP, policy, time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "magic link rate limit failed", "error", err)
+	} else if !result.Allowed {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(result.RetryAfter.Seconds()))))
+		utils.WriteProblem(w, r, http.StatusTooManyRequests, utils.ErrCodeTooManyRequests, "Too many requests")
+		return
+	}
+
+	user, err := db.GetUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "get user by email failed", "error", err)
+		}
+		utils.WriteJson(w, http.StatusAccepted, response)
+		return
+	}
+
+	// the link is created and mailed after the response, so it takes the same
+	// time whether the account exists or not
+	utils.RunInBackground(r.Context(), magicLinkSendTimeout, func(ctx context.Context) {
+		sendMagicLink(ctx, user, clientIP, config)
+	})
+
+	utils.WriteJson(w, http.StatusAccepted, response)
+}
+
+// sendMagicLink creates the login link and emails it, unless the user already
+// got the maximum number of links in the window
+func sendMagicLink(ctx context.Context, user *db.User, clientIP string, config utils.MagicLinkConfig) {
+	byUser, err := db.CountRecentMagicLinks(ctx, user.ID, time.Now().Add(-config.Window))
+	if err != nil {
+		slog.ErrorContext(ctx, "count recent magic links failed", "error", err)
+		return
+	}
+	if byUser >= config.MaxPerEmail {
+		return
+	}
+
+	token, tokenHash, err := utils.GenerateOAuthToken("")
+	if err != nil {
+		slog.ErrorContext(ctx, "generate oauth token failed", "error", err)
+		return
+	}
+
+	err = db.CreateMagicLink(ctx, tokenHash, user.ID, clientIP, time.Now().Add(config.TTL))
+	if err != nil {
+		slog.ErrorContext(ctx, "create magic link failed", "error", err)
+		return
+	}
+
+	link := config.URL + "?" + url.Values{"token": {token}}.Encode()
+	body := fmt.Sprintf(
+		"Use the link below to sign in. It expires in %d minutes and can be used once.\r\n\r\n%s\r\n\r\nIf you did not request it, you can ignore this email.",
+		int(config.TTL.Minutes()),
+		link,
+	)
+
+	err = utils.GetMailer().Send(ctx, user.Email, "Your login link", body)
+	if err != nil {
+		slog.ErrorContext(ctx, "send magic link email failed", "error", err)
+	}
+}
+
+// MagicLinkPage renders a confirmation page without consuming the token
+func MagicLinkPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeValidation, "Token is required")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	err := magicLinkTemplate.Execute(w, token)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "render magic link page failed", "error", err)
+	}
+}
+
+// ConsumeMagicLink exchanges the token for the same response as Login,
+// accepts JSON body or the form submitted by the verify page
+func ConsumeMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req MagicLinkVerifyRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		req.Token = r.PostFormValue("token")
+	} else {
+		if err := utils.DecodeJSON(r, &req); err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+	}
+
+	if err := validation.Validate(req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	userID, err := db.ConsumeMagicLink(r.Context(), utils.HashToken(req.Token))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "consume magic link failed", "error", err)
+		}
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidToken, "Invalid or expired login link")
+		return
+	}
+
+	user, err := db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user by id failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: user not found")
+		return
+	}
+
+	writeAuthResponse(w, r, user, utils.WantsCookieSession(r), "Login successful", http.StatusOK)
+}
@@ -0,0 +1,756 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/middleware"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// authorizeRequest is a validated authorization request
+type authorizeRequest struct {
+	Client        *db.OAuthClient
+	RedirectURI   string
+	State         string
+	Nonce         string
+	CodeChallenge string
+	Scopes        []string
+	Prompt        string
+}
+
+type ConsentResponse struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	Scopes      []string `json:"scopes"`
+	RedirectURI string   `json:"redirect_uri"`
+	State       string   `json:"state,omitempty"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>Authorize {{.ClientName}}</title></head>
+<body>
+  <h1>{{.ClientName}} wants to access your account</h1>
+  <ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+  <form method="post" action="authorize">
+    {{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+    {{end}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+    <button type="submit" name="decision" value="approve">Allow</button>
+    <button type="submit" name="decision" value="deny">Deny</button>
+  </form>
+</body>
+</html>`))
+
+func writeOAuthError(w http.ResponseWriter, statusCode int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	utils.WriteJson(w, statusCode, utils.OAuthError{Code: code, Description: description})
+}
+
+// redirectOAuthError sends the error back to the client redirect uri
+func redirectOAuthError(w http.ResponseWriter, r *http.Request, req *authorizeRequest, code, description string) {
+	params := url.Values{}
+	params.Set("error", code)
+	params.Set("error_description", description)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", utils.GetOAuthIssuer())
+	http.Redirect(w, r, appendQuery(req.RedirectURI, params), http.StatusFound)
+}
+
+func appendQuery(rawURL string, params url.Values) string {
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	return rawURL + separator + params.Encode()
+}
+
+// parseAuthorizeRequest validates the request, errors before the redirect uri
+// is verified must not redirect so the returned flag tells if redirecting is safe
+func parseAuthorizeRequest(ctx context.Context, values url.Values) (*authorizeRequest, *utils.OAuthError, bool) {
+	client, err := db.GetOAuthClient(ctx, values.Get("client_id"))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(ctx, "get oauth client failed", "error", err)
+		}
+		return nil, &utils.OAuthError{Code: "invalid_client", Description: "unknown client"}, false
+	}
+
+	req := &authorizeRequest{
+		Client:        client,
+		RedirectURI:   values.Get("redirect_uri"),
+		State:         values.Get("state"),
+		Nonce:         values.Get("nonce"),
+		CodeChallenge: values.Get("code_challenge"),
+		Scopes:        utils.ParseScopes(values.Get("scope")),
+		Prompt:        values.Get("prompt"),
+	}
+
+	// redirect uri is optional only when the client registered exactly one
+	if req.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		req.RedirectURI = client.RedirectURIs[0]
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, &utils.OAuthError{Code: "invalid_request", Description: "redirect_uri is not registered"}, false
+	}
+
+	if values.Get("response_type") != "code" {
+		return req, &utils.OAuthError{Code: "unsupported_response_type", Description: "only code response type is supported"}, true
+	}
+	if !slices.Contains(client.GrantTypes, utils.OAuthGrantAuthorizationCode) {
+		return req, &utils.OAuthError{Code: "unauthorized_client", Description: "client is not allowed to use authorization code grant"}, true
+	}
+	if len(req.Scopes) == 0 || !utils.ScopesSubset(req.Scopes, client.Scopes) {
+		return req, &utils.OAuthError{Code: "invalid_scope", Description: "requested scope is not allowed for this client"}, true
+	}
+
+	// PKCE is mandatory for public clients and S256 is the only supported method
+	if req.CodeChallenge == "" && !client.Confidential() {
+		return req, &utils.OAuthError{Code: "invalid_request", Description: "code_challenge is required"}, true
+	}
+	if req.CodeChallenge != "" && values.Get("code_challenge_method") != "S256" {
+		return req, &utils.OAuthError{Code: "invalid_request", Description: "code_challenge_method must be S256"}, true
+	}
+
+	return req, nil, true
+}
+
+// OAuthLoginRequired handles authorize requests of browsers without a valid
+// session cookie: the user is sent to the configured login page and comes back
+// through return_to, or the client gets login_required. Other requests go on
+// to the session auth middlewares
+func OAuthLoginRequired(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasClientCert := middleware.GetClientIdentityFromContext(r)
+		if r.Header.Get("Authorization") != "" || hasValidSessionCookie(r) || (hasClientCert && utils.GetTLSConfig().ClientCertAuth) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		req, oauthErr, redirectable := parseAuthorizeRequest(r.Context(), r.URL.Query())
+		if oauthErr != nil {
+			if redirectable {
+				redirectOAuthError(w, r, req, oauthErr.Code, oauthErr.Description)
+				return
+			}
+			writeOAuthError(w, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+			return
+		}
+
+		loginURL := utils.GetOAuthLoginURL()
+		if loginURL == "" || req.Prompt == "none" {
+			redirectOAuthError(w, r, req, "login_required", "user is not logged in")
+			return
+		}
+		http.Redirect(w, r, appendQuery(loginURL, url.Values{"return_to": {oauthReturnTo(r)}}), http.StatusFound)
+	})
+}
+
+// oauthReturnTo is the absolute authorize url to come back to after login.
+// r.URL lost the /oauth prefix to Mount, RequestURI is what the client sent
+func oauthReturnTo(r *http.Request) string {
+	requestURI := r.RequestURI
+	if requestURI == "" {
+		requestURI = r.URL.RequestURI()
+	}
+	return utils.GetOAuthIssuer() + requestURI
+}
+
+func hasValidSessionCookie(r *http.Request) bool {
+	cookieConfig := utils.GetCookieConfig()
+	if !cookieConfig.Enabled {
+		return false
+	}
+	cookie, err := r.Cookie(cookieConfig.Name)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	_, err = utils.ValidateJWTToken(cookie.Value)
+	return err == nil
+}
+
+// OAuthAuthorize shows the consent prompt or redirects back with a code when
+// the user already granted the requested scopes
+func OAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	values := r.URL.Query()
+	req, oauthErr, redirectable := parseAuthorizeRequest(r.Context(), values)
+	if oauthErr != nil {
+		if redirectable {
+			redirectOAuthError(w, r, req, oauthErr.Code, oauthErr.Description)
+			return
+		}
+		writeOAuthError(w, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		return
+	}
+
+	consented, err := db.GetOAuthConsent(r.Context(), userID, req.Client.ClientID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get oauth consent failed", "error", err)
+		redirectOAuthError(w, r, req, "server_error", "internal server error")
+		return
+	}
+
+	if req.Prompt != "consent" && utils.ScopesSubset(req.Scopes, consented) {
+		issueAuthorizationCode(w, r, req, userID)
+		return
+	}
+	if req.Prompt == "none" {
+		redirectOAuthError(w, r, req, "consent_required", "user consent is required")
+		return
+	}
+
+	consent := ConsentResponse{
+		ClientID:    req.Client.ClientID,
+		ClientName:  req.Client.Name,
+		Scopes:      req.Scopes,
+		RedirectURI: req.RedirectURI,
+		State:       req.State,
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		utils.WriteJson(w, http.StatusOK, consent)
+		return
+	}
+
+	// minimal consent page for browser clients, posts back to this endpoint
+	params := map[string]string{}
+	for _, name := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+		if values.Get(name) != "" {
+			params[name] = values.Get(name)
+		}
+	}
+	var csrfToken string
+	if cookie, err := r.Cookie(utils.GetCookieConfig().CSRFName); err == nil {
+		csrfToken = cookie.Value
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	err = consentTemplate.Execute(w, map[string]any{
+		"ClientName": consent.ClientName,
+		"Scopes":     consent.Scopes,
+		"Params":     params,
+		"CSRFToken":  csrfToken,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "render consent page failed", "error", err)
+	}
+}
+
+// OAuthAuthorizeDecision handles the consent form submission
+func OAuthAuthorizeDecision(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid form body")
+		return
+	}
+
+	req, oauthErr, redirectable := parseAuthorizeRequest(r.Context(), r.PostForm)
+	if oauthErr != nil {
+		if redirectable {
+			redirectOAuthError(w, r, req, oauthErr.Code, oauthErr.Description)
+			return
+		}
+		writeOAuthError(w, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "approve" {
+		redirectOAuthError(w, r, req, "access_denied", "user denied the request")
+		return
+	}
+
+	// remember granted scopes so next authorization skips the prompt
+	consented, err := db.GetOAuthConsent(r.Context(), userID, req.Client.ClientID)
+	if err == nil {
+		for _, scope := range req.Scopes {
+			if !slices.Contains(consented, scope) {
+				consented = append(consented, scope)
+			}
+		}
+		err = db.SaveOAuthConsent(r.Context(), userID, req.Client.ClientID, consented)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "save oauth consent failed", "error", err)
+		redirectOAuthError(w, r, req, "server_error", "internal server error")
+		return
+	}
+
+	issueAuthorizationCode(w, r, req, userID)
+}
+
+func issueAuthorizationCode(w http.ResponseWriter, r *http.Request, req *authorizeRequest, userID int64) {
+	code, codeHash, err := utils.GenerateOAuthToken("")
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate oauth token failed", "error", err)
+		redirectOAuthError(w, r, req, "server_error", "internal server error")
+		return
+	}
+
+	now := time.Now()
+	err = db.CreateOAuthCode(r.Context(), &db.OAuthCode{
+		CodeHash:      codeHash,
+		ClientID:      req.Client.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        req.Scopes,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(utils.OAuthCodeTTL),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create oauth code failed", "error", err)
+		redirectOAuthError(w, r, req, "server_error", "internal server error")
+		return
+	}
+
+	params := url.Values{}
+	params.Set("code", code)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", utils.GetOAuthIssuer())
+	http.Redirect(w, r, appendQuery(req.RedirectURI, params), http.StatusSeeOther)
+}
+
+// authenticateOAuthClient authenticates the client with client_secret_basic,
+// client_secret_post or as a public client with client_id only
+func authenticateOAuthClient(w http.ResponseWriter, r *http.Request) (*db.OAuthClient, bool) {
+	clientID, clientSecret, basicAuth := r.BasicAuth()
+	if basicAuth {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	fail := func() (*db.OAuthClient, bool) {
+		if basicAuth {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return nil, false
+	}
+
+	if clientID == "" {
+		return fail()
+	}
+
+	client, err := db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "get oauth client failed", "error", err)
+		}
+		return fail()
+	}
+
+	if client.Confidential() {
+		if subtle.ConstantTimeCompare([]byte(utils.HashToken(clientSecret)), []byte(client.ClientSecretHash)) != 1 {
+			return fail()
+		}
+	} else if clientSecret != "" {
+		return fail()
+	}
+
+	return client, true
+}
+
+// OAuthToken is the token endpoint supporting authorization code (with PKCE),
+// client credentials and refresh token grants
+func OAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid form body")
+		return
+	}
+
+	client, ok := authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	grantType := r.PostForm.Get("grant_type")
+	if !slices.Contains(utils.OAuthGrantTypes, grantType) {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant type")
+		return
+	}
+	if !slices.Contains(client.GrantTypes, grantType) {
+		writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "client is not allowed to use this grant type")
+		return
+	}
+
+	switch grantType {
+	case utils.OAuthGrantAuthorizationCode:
+		grantID, _, err := utils.GenerateOAuthToken("")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "generate oauth token failed", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal server error")
+			return
+		}
+
+		codeHash := utils.HashToken(r.PostForm.Get("code"))
+		code, err := db.ConsumeOAuthCode(r.Context(), codeHash, client.ClientID, grantID)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				slog.ErrorContext(r.Context(), "consume oauth code failed", "error", err)
+			} else if revoked, err := db.RevokeOAuthCodeGrant(r.Context(), codeHash, client.ClientID); err != nil {
+				slog.ErrorContext(r.Context(), "revoke oauth code grant failed", "error", err)
+			} else if revoked {
+				// the code leaked, tokens issued from it can't be trusted either
+				slog.WarnContext(r.Context(), "oauth code replayed, tokens revoked", "client_id", client.ClientID)
+			}
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+			return
+		}
+		if code.ExpiresAt.Before(time.Now()) || code.RedirectURI != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+			return
+		}
+		if code.CodeChallenge != "" && !utils.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid code verifier")
+			return
+		}
+
+		issueOAuthTokens(w, r, client, &code.UserID, code.Scopes, grantID, code.Nonce, code.AuthTime)
+
+	case utils.OAuthGrantClientCredentials:
+		if !client.Confidential() {
+			writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "client credentials grant requires a confidential client")
+			return
+		}
+		scopes := utils.ParseScopes(r.PostForm.Get("scope"))
+		if !utils.ScopesSubset(scopes, client.Scopes) || slices.Contains(scopes, "openid") || slices.Contains(scopes, "offline_access") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "requested scope is not allowed for this client")
+			return
+		}
+
+		grantID, _, err := utils.GenerateOAuthToken("")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "generate oauth token failed", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal server error")
+			return
+		}
+		issueOAuthTokens(w, r, client, nil, scopes, grantID, "", time.Now())
+
+	case utils.OAuthGrantRefreshToken:
+		// refresh tokens are rotated, the presented one is consumed
+		refreshToken, err := db.ConsumeOAuthRefreshToken(r.Context(), utils.HashToken(r.PostForm.Get("refresh_token")))
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				slog.ErrorContext(r.Context(), "consume oauth refresh token failed", "error", err)
+			}
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+			return
+		}
+		if refreshToken.ClientID != client.ClientID || refreshToken.ExpiresAt.Before(time.Now()) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "invalid refresh token")
+			return
+		}
+
+		scopes := refreshToken.Scopes
+		if requested := utils.ParseScopes(r.PostForm.Get("scope")); len(requested) > 0 {
+			if !utils.ScopesSubset(requested, refreshToken.Scopes) {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "requested scope exceeds the original grant")
+				return
+			}
+			scopes = requested
+		}
+		issueOAuthTokens(w, r, client, refreshToken.UserID, scopes, refreshToken.GrantID, "", refreshToken.AuthTime)
+	}
+}
+
+func issueOAuthTokens(w http.ResponseWriter, r *http.Request, client *db.OAuthClient, userID *int64, scopes []string, grantID, nonce string, authTime time.Time) {
+	now := time.Now()
+
+	accessToken, accessTokenHash, err := utils.GenerateOAuthToken(utils.OAuthAccessTokenPrefix)
+	if err == nil {
+		err = db.CreateOAuthToken(r.Context(), &db.OAuthToken{
+			TokenHash: accessTokenHash,
+			TokenType: utils.OAuthTokenTypeAccess,
+			GrantID:   grantID,
+			ClientID:  client.ClientID,
+			UserID:    userID,
+			Scopes:    scopes,
+			AuthTime:  authTime,
+			ExpiresAt: now.Add(utils.OAuthAccessTokenTTL),
+		})
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create oauth token failed", "error", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal server error")
+		return
+	}
+
+	response := OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(utils.OAuthAccessTokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}
+
+	// refresh tokens are only issued on behalf of users
+	if userID != nil && slices.Contains(client.GrantTypes, utils.OAuthGrantRefreshToken) {
+		var refreshTokenHash string
+		response.RefreshToken, refreshTokenHash, err = utils.GenerateOAuthToken(utils.OAuthRefreshTokenPrefix)
+		if err == nil {
+			err = db.CreateOAuthToken(r.Context(), &db.OAuthToken{
+				TokenHash: refreshTokenHash,
+				TokenType: utils.OAuthTokenTypeRefresh,
+				GrantID:   grantID,
+				ClientID:  client.ClientID,
+				UserID:    userID,
+				Scopes:    scopes,
+				AuthTime:  authTime,
+				ExpiresAt: now.Add(utils.OAuthRefreshTokenTTL),
+			})
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "create oauth token failed", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal server error")
+			return
+		}
+	}
+
+	if userID != nil && slices.Contains(scopes, "openid") {
+		user, err := db.GetUserByID(r.Context(), *userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "get user by id failed", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal server error")
+			return
+		}
+
+		claims := utils.IDTokenClaims{
+			Issuer:    utils.GetOAuthIssuer(),
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  client.ClientID,
+			ExpiresAt: now.Add(utils.OAuthAccessTokenTTL).Unix(),
+			IssuedAt:  now.Unix(),
+			AuthTime:  authTime.Unix(),
+			Nonce:     nonce,
+		}
+		fillUserClaims(&claims, user, scopes)
+
+		response.IDToken, err = utils.SignJWS(claims)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "sign JWS failed", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "internal server error")
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	utils.WriteJson(w, http.StatusOK, response)
+}
+
+func fillUserClaims(claims *utils.IDTokenClaims, user *db.User, scopes []string) {
+	if slices.Contains(scopes, "email") {
+		claims.Email = user.Email
+	}
+	if slices.Contains(scopes, "profile") {
+		claims.GivenName = user.FirstName
+		claims.FamilyName = user.LastName
+		claims.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+}
+
+// OAuthUserInfo returns claims about the user the access token was issued for
+func OAuthUserInfo(w http.ResponseWriter, r *http.Request) {
+	tokenString, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || tokenString == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "access token is missing")
+		return
+	}
+
+	token, err := db.GetOAuthToken(r.Context(), utils.HashToken(tokenString))
+	if err != nil || token.TokenType != utils.OAuthTokenTypeAccess || token.UserID == nil {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "get oauth token failed", "error", err)
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "access token is invalid or expired")
+		return
+	}
+	if !slices.Contains(token.Scopes, "openid") {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		writeOAuthError(w, http.StatusForbidden, "insufficient_scope", "openid scope is required")
+		return
+	}
+
+	user, err := db.GetUserByID(r.Context(), *token.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user by id failed", "error", err)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "user not found")
+		return
+	}
+
+	claims := utils.IDTokenClaims{Subject: strconv.FormatInt(user.ID, 10)}
+	fillUserClaims(&claims, user, token.Scopes)
+
+	utils.WriteJson(w, http.StatusOK, claims)
+}
+
+// OAuthIntrospect implements RFC 7662 token introspection for confidential clients
+func OAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid form body")
+		return
+	}
+
+	client, ok := authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+	if !client.Confidential() {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "introspection requires a confidential client")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	token, err := db.GetOAuthToken(r.Context(), utils.HashToken(r.PostForm.Get("token")))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "get oauth token failed", "error", err)
+		}
+		utils.WriteJson(w, http.StatusOK, IntrospectionResponse{Active: false})
+		return
+	}
+
+	response := IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(token.Scopes, " "),
+		ClientID:  token.ClientID,
+		TokenType: token.TokenType,
+		ExpiresAt: token.ExpiresAt.Unix(),
+		IssuedAt:  token.CreatedAt.Unix(),
+		Issuer:    utils.GetOAuthIssuer(),
+	}
+	if token.UserID != nil {
+		response.Subject = strconv.FormatInt(*token.UserID, 10)
+	}
+
+	utils.WriteJson(w, http.StatusOK, response)
+}
+
+// OAuthRevoke implements RFC 7009 token revocation, revoking a refresh token
+// also revokes access tokens issued from the same grant
+func OAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid form body")
+		return
+	}
+
+	client, ok := authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	tokenHash := utils.HashToken(r.PostForm.Get("token"))
+	token, err := db.GetOAuthToken(r.Context(), tokenHash)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "get oauth token failed", "error", err)
+			writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "try again later")
+			return
+		}
+		// unknown tokens are not an error per RFC 7009
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if token.ClientID == client.ClientID {
+		if token.TokenType == utils.OAuthTokenTypeRefresh {
+			err = db.DeleteOAuthGrant(r.Context(), token.GrantID)
+		} else {
+			err = db.DeleteOAuthToken(r.Context(), tokenHash)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "delete oauth token failed", "error", err)
+			writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "try again later")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// OAuthJWKS publishes the public keys used to sign ID tokens
+func OAuthJWKS(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJson(w, http.StatusOK, utils.SigningJWKS())
+}
+
+// OpenIDConfiguration serves the OIDC discovery metadata
+func OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	issuer := utils.GetOAuthIssuer()
+	utils.WriteJson(w, http.StatusOK, map[string]any{
+		"issuer":                                         issuer,
+		"authorization_endpoint":                         issuer + "/oauth/authorize",
+		"token_endpoint":                                 issuer + "/oauth/token",
+		"userinfo_endpoint":                              issuer + "/oauth/userinfo",
+		"jwks_uri":                                       issuer + "/oauth/jwks",
+		"introspection_endpoint":                         issuer + "/oauth/introspect",
+		"revocation_endpoint":                            issuer + "/oauth/revoke",
+		"scopes_supported":                               utils.OAuthScopes,
+		"response_types_supported":                       []string{"code"},
+		"grant_types_supported":                          utils.OAuthGrantTypes,
+		"subject_types_supported":                        []string{"public"},
+		"id_token_signing_alg_values_supported":          []string{"RS256"},
+		"code_challenge_methods_supported":               []string{"S256"},
+		"token_endpoint_auth_methods_supported":          []string{"client_secret_basic", "client_secret_post", "none"},
+		"claims_supported":                               []string{"sub", "email", "name", "given_name", "family_name"},
+		"authorization_response_iss_parameter_supported": true,
+	})
+}
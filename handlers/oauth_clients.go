@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/middleware"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+	"github.com/olksndrdevhub/go-api-starter-kit/validation"
+)
+
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"url"`
+	Confidential bool     `json:"confidential"` // public clients (SPA, mobile) must use PKCE
+	GrantTypes   []string `json:"grant_types" validate:"oauth_grant_types"`
+	Scopes       []string `json:"scopes" validate:"oauth_scopes"`
+}
+
+type CreateOAuthClientResponse struct {
+	db.OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"` // shown only once
+}
+
+func ListOAuthClients(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	clients, err := db.GetOAuthClientsByOwner(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list oauth clients failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, clients)
+}
+
+func CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	var req CreateOAuthClientRequest
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := validation.Validate(req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	if len(req.GrantTypes) == 0 {
+		req.GrantTypes = []string{utils.OAuthGrantAuthorizationCode, utils.OAuthGrantRefreshToken}
+	}
+	if slices.Contains(req.GrantTypes, utils.OAuthGrantClientCredentials) && !req.Confidential {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Client credentials grant requires a confidential client")
+		return
+	}
+
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	if slices.Contains(req.GrantTypes, utils.OAuthGrantAuthorizationCode) && len(req.RedirectURIs) == 0 {
+		utils.WriteError(w, r, utils.NewValidationError(utils.FieldError{Field: "redirect_uris", Code: "required", Message: "is required for the authorization_code grant"}))
+		return
+	}
+
+	clientID, _, err := utils.GenerateOAuthToken("cid_")
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate oauth token failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	client := &db.OAuthClient{
+		ClientID:     clientID,
+		Name:         strings.TrimSpace(req.Name),
+		RedirectURIs: req.RedirectURIs,
+		GrantTypes:   req.GrantTypes,
+		Scopes:       req.Scopes,
+		OwnerID:      userID,
+	}
+
+	// only the hash of the secret is stored
+	var clientSecret string
+	if req.Confidential {
+		clientSecret, client.ClientSecretHash, err = utils.GenerateOAuthToken("cs_")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "generate oauth token failed", "error", err)
+			utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+			return
+		}
+	}
+
+	client, err = db.CreateOAuthClient(r.Context(), client)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "create oauth client failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	response := CreateOAuthClientResponse{
+		OAuthClient:  *client,
+		ClientSecret: clientSecret,
+	}
+
+	utils.WriteJson(w, http.StatusCreated, response)
+}
+
+func DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	deleted, err := db.DeleteOAuthClient(r.Context(), userID, r.PathValue("client_id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "delete oauth client failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+	if !deleted {
+		utils.WriteProblem(w, r, http.StatusNotFound, utils.ErrCodeNotFound, "Client not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/middleware"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+func TestOAuthReturnToKeepsMountPrefix(t *testing.T) {
+	previous := utils.GetOAuthIssuer()
+	utils.SetOAuthIssuer("https://auth.example.com")
+	t.Cleanup(func() { utils.SetOAuthIssuer(previous) })
+
+	var returnTo string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /authorize", func(w http.ResponseWriter, r *http.Request) {
+		returnTo = oauthReturnTo(r)
+	})
+	handler := middleware.Mount("/oauth", mux)
+
+	r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?client_id=app&scope=openid+email", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	want := "https://auth.example.com/oauth/authorize?client_id=app&scope=openid+email"
+	if returnTo != want {
+		t.Errorf("return_to = %q, want %q", returnTo, want)
+	}
+}
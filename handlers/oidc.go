@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// OIDCLogin redirects the user to the provider authorization endpoint
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := utils.GetOIDCProvider(r.PathValue("provider"))
+	if !ok {
+		utils.WriteProblem(w, r, http.StatusNotFound, utils.ErrCodeNotFound, "Unknown provider")
+		return
+	}
+
+	state, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate random token failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate random token failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+	codeVerifier, codeChallenge, err := utils.GeneratePKCE()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate PKCE failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, codeChallenge)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "build authorization URL failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadGateway, utils.ErrCodeUpstream, "Identity provider is unavailable")
+		return
+	}
+
+	// keep state, nonce and verifier in a signed cookie until the callback
+	cookieValue, err := utils.EncodeOIDCState(utils.OIDCState{
+		Provider:      provider.Name(),
+		State:         state,
+		Nonce:         nonce,
+		CodeVerifier:  codeVerifier,
+		ExpiresAt:     time.Now().Add(oidcStateTTL).Unix(),
+		CookieSession: utils.WantsCookieSession(r),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "encode OIDC state failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    cookieValue,
+		Path:     "/",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   utils.GetCookieConfig().Secure,
+		SameSite: http.SameSiteLaxMode, // must survive the top level redirect back from the provider
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback completes the authorization code flow, links or creates the user
+// and issues the same response as Login
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := utils.GetOIDCProvider(r.PathValue("provider"))
+	if !ok {
+		utils.WriteProblem(w, r, http.StatusNotFound, utils.ErrCodeNotFound, "Unknown provider")
+		return
+	}
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Authorization failed: "+errCode)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Login session is missing or expired")
+		return
+	}
+	// state cookie is single use
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+
+	state, err := utils.DecodeOIDCState(cookie.Value)
+	if err != nil || state.Provider != provider.Name() || query.Get("state") == "" || query.Get("state") != state.State {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid login state")
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Authorization code is missing")
+		return
+	}
+
+	tokens, err := provider.Exchange(r.Context(), code, state.CodeVerifier)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC code exchange failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadGateway, utils.ErrCodeUpstream, "Error exchanging authorization code")
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Context(), tokens.IDToken, state.Nonce)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "verify ID token failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidToken, "Invalid ID token")
+		return
+	}
+
+	user, err := resolveOIDCUser(r.Context(), provider.Name(), claims)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	writeAuthResponse(w, r, user, state.CookieSession && utils.GetCookieConfig().Enabled, "Login successful", http.StatusOK)
+}
+
+// resolveOIDCUser finds the user linked to the provider identity, links an existing user
+// with the same verified email or creates a new passwordless user
+func resolveOIDCUser(ctx context.Context, provider string, claims *utils.OIDCIDTokenClaims) (*db.User, error) {
+	identity, err := db.GetUserIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		user, err := db.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	email, err := oidcLinkEmail(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := db.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		firstName, lastName := claims.GivenName, claims.FamilyName
+		if firstName == "" && lastName == "" {
+			firstName, lastName, _ = strings.Cut(claims.Name, " ")
+		}
+		user, err = db.CreateUser(ctx, email, "", firstName, lastName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.CreateUserIdentity(ctx, user.ID, provider, claims.Subject, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// oidcLinkEmail returns the email a new provider identity is linked or registered
+// by, linking by email is only safe when the provider verified it
+func oidcLinkEmail(claims *utils.OIDCIDTokenClaims) (string, error) {
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return "", utils.NewAPIError(http.StatusForbidden, utils.ErrCodeForbidden, "Provider account has no verified email")
+	}
+	return email, nil
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+func TestOIDCLinkEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   utils.OIDCIDTokenClaims
+		want     string
+		wantLink bool
+	}{
+		{"verified", utils.OIDCIDTokenClaims{Email: "user@example.com", EmailVerified: true}, "user@example.com", true},
+		{"verified with spaces", utils.OIDCIDTokenClaims{Email: " user@example.com ", EmailVerified: true}, "user@example.com", true},
+		{"unverified", utils.OIDCIDTokenClaims{Email: "user@example.com"}, "", false},
+		{"no email", utils.OIDCIDTokenClaims{EmailVerified: true}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email, err := oidcLinkEmail(&tt.claims)
+			if !tt.wantLink {
+				var apiErr *utils.APIError
+				if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+					t.Fatalf("err = %v, want 403 api error", err)
+				}
+				return
+			}
+			if err != nil || email != tt.want {
+				t.Fatalf("oidcLinkEmail = %q, %v, want %q", email, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRejectsState(t *testing.T) {
+	err := utils.RegisterOIDCProvider(utils.OIDCProviderConfig{
+		Name:        "callback-test",
+		Issuer:      "https://idp.invalid",
+		ClientID:    "client",
+		RedirectURL: "https://app.example.com/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validState := func(provider string, expiresAt time.Time) string {
+		value, err := utils.EncodeOIDCState(utils.OIDCState{Provider: provider, State: "the-state", Nonce: "n", CodeVerifier: "v", ExpiresAt: expiresAt.Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie string
+		want   int
+	}{
+		{"provider error", "?error=access_denied", "", http.StatusUnauthorized},
+		{"no state cookie", "?code=c&state=the-state", "", http.StatusBadRequest},
+		{"state mismatch", "?code=c&state=other", validState("callback-test", time.Now().Add(time.Minute)), http.StatusBadRequest},
+		{"no state", "?code=c", validState("callback-test", time.Now().Add(time.Minute)), http.StatusBadRequest},
+		{"other provider", "?code=c&state=the-state", validState("other", time.Now().Add(time.Minute)), http.StatusBadRequest},
+		{"expired", "?code=c&state=the-state", validState("callback-test", time.Now().Add(-time.Minute)), http.StatusBadRequest},
+		{"forged", "?code=c&state=the-state", "e30.forged", http.StatusBadRequest},
+		{"no code", "?state=the-state", validState("callback-test", time.Now().Add(time.Minute)), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/callback-test/callback"+tt.query, nil)
+			r.SetPathValue("provider", "callback-test")
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			OIDCCallback(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -1,15 +1,13 @@
 package handlers
 
 import (
-	"encoding/json"
-	"errors"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
 
 	"github.com/olksndrdevhub/go-api-starter-kit/db"
 	"github.com/olksndrdevhub/go-api-starter-kit/middleware"
 	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+	"github.com/olksndrdevhub/go-api-starter-kit/validation"
 )
 
 type ProfileResponse struct {
@@ -17,28 +15,28 @@ type ProfileResponse struct {
 }
 
 type ProfileUpdateRequest struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
+	FirstName string `json:"first_name" validate:"required_without=LastName,max=100"`
+	LastName  string `json:"last_name" validate:"required_without=FirstName,max=100"`
 }
 
 type ChangePasswordRequest struct {
-	Password        string `json:"password"`
-	NewPassword     string `json:"new_password"`
-	ConfirmPassword string `json:"confirm_password"`
+	Password        string `json:"password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=NewPassword"`
 }
 
 func Profile(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r)
 	if !ok {
-		log.Printf("ERORR: %v", errors.New("user id not found"))
-		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	user, err := db.GetUserByID(userID)
+	user, err := db.GetUserByID(r.Context(), userID)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"message": "user not found, bad credentials"})
+		slog.ErrorContext(r.Context(), "get user by id failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "User not found, bad credentials")
 		return
 	}
 
@@ -49,14 +47,13 @@ func Profile(w http.ResponseWriter, r *http.Request) {
 		utils.WriteJson(w, http.StatusOK, response)
 	} else if r.Method == http.MethodPatch {
 		var req ProfileUpdateRequest
-		err := json.NewDecoder(r.Body).Decode(&req)
-		if err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if err := utils.DecodeJSON(r, &req); err != nil {
+			utils.WriteError(w, r, err)
 			return
 		}
 
-		if strings.TrimSpace(req.FirstName) == "" && strings.TrimSpace(req.LastName) == "" {
-			utils.WriteJson(w, http.StatusBadRequest, map[string]string{"message": "at least first name or last name are required"})
+		if err := validation.Validate(req); err != nil {
+			utils.WriteError(w, r, err)
 			return
 		}
 
@@ -69,10 +66,10 @@ func Profile(w http.ResponseWriter, r *http.Request) {
 			updatedLastName = req.LastName
 		}
 
-		err = db.UpdateUser(userID, updatedFirstName, updatedLastName)
+		err = db.UpdateUser(r.Context(), userID, updatedFirstName, updatedLastName)
 		if err != nil {
-			log.Printf("ERROR: %v", err)
-			utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"message": "internal server error"})
+			slog.ErrorContext(r.Context(), "update user failed", "error", err)
+			utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
 			return
 		}
 
@@ -81,75 +78,61 @@ func Profile(w http.ResponseWriter, r *http.Request) {
 		// TODO: delete user
 		utils.WriteJson(w, http.StatusNoContent, nil)
 	} else {
-		utils.WriteJson(w, http.StatusMethodNotAllowed, map[string]string{"message": "method not allowed"})
+		utils.WriteProblem(w, r, http.StatusMethodNotAllowed, utils.ErrCodeMethodNotAllowed, "Method not allowed")
 	}
 }
 
 func ChangePassword(w http.ResponseWriter, r *http.Request) {
 	var req ChangePasswordRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	userID, ok := middleware.GetUserIDFromContext(r)
 	if !ok {
-		log.Printf("ERORR: %v", errors.New("user id not found"))
-		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	if strings.TrimSpace(req.Password) == "" || strings.TrimSpace(req.NewPassword) == "" || strings.TrimSpace(req.ConfirmPassword) == "" {
-		http.Error(w, "Current password, new password and confirm password are required", http.StatusBadRequest)
+	if err := validation.Validate(req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	//check if current password is correct
-	user, err := db.GetUserByID(userID)
+	user, err := db.GetUserByID(r.Context(), userID)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"message": "user not found, bad credentials"})
+		slog.ErrorContext(r.Context(), "get user by id failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "User not found, bad credentials")
 		return
 	}
 
-	match, err := utils.VerifyPassword(req.Password, user.Password)
+	match, err := utils.VerifyPassword(r.Context(), req.Password, user.Password)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "verify password failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
 		return
 	}
 	if !match {
-		http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
-		return
-	}
-
-	// check if new password and confirm password match
-	if req.NewPassword != req.ConfirmPassword {
-		http.Error(w, "New password and confirm password do not match", http.StatusBadRequest)
-		return
-	}
-
-	// check if new password is valid
-	err = utils.ValidatePassword(req.NewPassword)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Current password is incorrect")
 		return
 	}
 
 	// hash new password
-	hashedNewPassword, err := utils.HashPassword(req.NewPassword)
+	hashedNewPassword, err := utils.HashPassword(r.Context(), req.NewPassword)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "hash password failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
 		return
 	}
 
 	// set new password
-	err = db.ChangeUserPassword(userID, hashedNewPassword)
+	err = db.ChangeUserPassword(r.Context(), userID, hashedNewPassword)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
-		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"message": "internal server error"})
+		slog.ErrorContext(r.Context(), "change user password failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
 		return
 	}
 
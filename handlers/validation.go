@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+	"github.com/olksndrdevhub/go-api-starter-kit/validation"
+)
+
+// request specific rules, allowed values come from the lists in utils
+func init() {
+	validation.RegisterRule("api_key_scopes", validation.Subset(utils.APIKeyScopes))
+	validation.RegisterRule("oauth_scopes", validation.Subset(utils.OAuthScopes))
+	validation.RegisterRule("oauth_grant_types", validation.Subset(utils.OAuthGrantTypes))
+}
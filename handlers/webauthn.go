@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/middleware"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+	"github.com/olksndrdevhub/go-api-starter-kit/validation"
+)
+
+const (
+	webAuthnCeremonyRegistration   = "registration"
+	webAuthnCeremonyAuthentication = "authentication"
+)
+
+type WebAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type WebAuthnRegistrationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUser                   `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection map[string]string              `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type WebAuthnUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type WebAuthnAuthenticationOptions struct {
+	Challenge        string                         `json:"challenge"`
+	RPID             string                         `json:"rpId"`
+	Timeout          int64                          `json:"timeout"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+type WebAuthnLoginBeginRequest struct {
+	Email string `json:"email" validate:"email"` // optional, empty for discoverable credentials
+}
+
+// WebAuthnRegistrationRequest is the serialized PublicKeyCredential from navigator.credentials.create
+type WebAuthnRegistrationRequest struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// WebAuthnLoginRequest is the serialized PublicKeyCredential from navigator.credentials.get
+type WebAuthnLoginRequest struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// webAuthnUserHandle is the opaque user id given to authenticators
+func webAuthnUserHandle(userID int64) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+func webAuthnCredentialDescriptors(ctx context.Context, userID int64) ([]WebAuthnCredentialDescriptor, error) {
+	credentials, err := db.GetWebAuthnCredentialsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptors := []WebAuthnCredentialDescriptor{}
+	for _, credential := range credentials {
+		descriptors = append(descriptors, WebAuthnCredentialDescriptor{Type: "public-key", ID: credential.CredentialID})
+	}
+	return descriptors, nil
+}
+
+// newWebAuthnChallenge creates and stores a single use challenge for the ceremony
+func newWebAuthnChallenge(ctx context.Context, ceremony string, userID *int64) (string, error) {
+	challenge, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = db.CreateWebAuthnSession(ctx, challenge, ceremony, userID, time.Now().Add(utils.GetWebAuthnConfig().Timeout))
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// consumeWebAuthnChallenge reads the challenge from client data and consumes its session
+func consumeWebAuthnChallenge(ctx context.Context, clientDataJSON []byte, ceremony string) (string, *int64, error) {
+	clientData, err := utils.ParseClientData(clientDataJSON)
+	if err != nil {
+		return "", nil, err
+	}
+
+	userID, err := db.ConsumeWebAuthnSession(ctx, clientData.Challenge, ceremony)
+	if err != nil {
+		return "", nil, err
+	}
+	return clientData.Challenge, userID, nil
+}
+
+// WebAuthnRegisterBegin returns credential creation options for the logged in user
+func WebAuthnRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user by id failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "User not found, bad credentials")
+		return
+	}
+
+	excludeCredentials, err := webAuthnCredentialDescriptors(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "webauthn credential descriptors failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	challenge, err := newWebAuthnChallenge(r.Context(), webAuthnCeremonyRegistration, &userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "new webauthn challenge failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	config := utils.GetWebAuthnConfig()
+	params := []WebAuthnCredentialParameter{}
+	for _, alg := range utils.WebAuthnAlgorithms {
+		params = append(params, WebAuthnCredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	options := WebAuthnRegistrationOptions{
+		Challenge: challenge,
+		RP:        WebAuthnRelyingParty{ID: config.RPID, Name: config.RPName},
+		User: WebAuthnUser{
+			ID:          utils.EncodeWebAuthnBytes(webAuthnUserHandle(user.ID)),
+			Name:        user.Email,
+			DisplayName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+		},
+		PubKeyCredParams:   params,
+		Timeout:            config.Timeout.Milliseconds(),
+		ExcludeCredentials: excludeCredentials,
+		AuthenticatorSelection: map[string]string{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+		Attestation: "none",
+	}
+
+	utils.WriteJson(w, http.StatusOK, options)
+}
+
+// WebAuthnRegisterFinish verifies the attestation and stores the new credential
+func WebAuthnRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	var req WebAuthnRegistrationRequest
+	// PublicKeyCredential.toJSON adds members this server doesn't use
+	// (clientExtensionResults, transports...), so unknown fields are allowed here
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		slog.ErrorContext(r.Context(), "user id not found in context")
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	rawID, err := utils.DecodeWebAuthnBytes(req.RawID)
+	clientDataJSON, clientDataErr := utils.DecodeWebAuthnBytes(req.Response.ClientDataJSON)
+	attestationObject, attestationErr := utils.DecodeWebAuthnBytes(req.Response.AttestationObject)
+	if err != nil || clientDataErr != nil || attestationErr != nil || req.Type != "public-key" {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid credential encoding")
+		return
+	}
+
+	challenge, sessionUserID, err := consumeWebAuthnChallenge(r.Context(), clientDataJSON, webAuthnCeremonyRegistration)
+	if err != nil || sessionUserID == nil || *sessionUserID != userID {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "consume webauthn challenge failed", "error", err)
+		}
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid or expired challenge")
+		return
+	}
+
+	credential, err := utils.VerifyWebAuthnRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		slog.WarnContext(r.Context(), "webauthn registration verification failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Registration verification failed")
+		return
+	}
+	if !bytes.Equal(credential.ID, rawID) {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Credential id mismatch")
+		return
+	}
+
+	stored, err := db.CreateWebAuthnCredential(
+		r.Context(),
+		userID,
+		utils.EncodeWebAuthnBytes(credential.ID),
+		credential.PublicKey,
+		int64(credential.SignCount),
+		credential.AAGUID,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			utils.WriteProblem(w, r, http.StatusConflict, utils.ErrCodeConflict, "Credential is already registered")
+			return
+		}
+		slog.ErrorContext(r.Context(), "create webauthn credential failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, stored)
+}
+
+// WebAuthnLoginBegin returns assertion options, with email the allowed credentials are listed,
+// without it the authenticator offers discoverable credentials
+func WebAuthnLoginBegin(w http.ResponseWriter, r *http.Request) {
+	var req WebAuthnLoginBeginRequest
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	if err := validation.Validate(req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	allowCredentials := []WebAuthnCredentialDescriptor{}
+	var userID *int64
+	if email := strings.TrimSpace(req.Email); email != "" {
+		user, err := db.GetUserByEmail(r.Context(), email)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "get user by email failed", "error", err)
+			utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+			return
+		}
+		if user != nil {
+			userID = &user.ID
+			allowCredentials, err = webAuthnCredentialDescriptors(r.Context(), user.ID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "webauthn credential descriptors failed", "error", err)
+				utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+				return
+			}
+		}
+		// unknown emails and accounts without passkeys get the same looking
+		// options as real ones, so this route can't be used to find accounts
+		if len(allowCredentials) == 0 {
+			for _, id := range utils.FakeWebAuthnCredentialIDs(email) {
+				allowCredentials = append(allowCredentials, WebAuthnCredentialDescriptor{Type: "public-key", ID: utils.EncodeWebAuthnBytes(id)})
+			}
+		}
+	}
+
+	challenge, err := newWebAuthnChallenge(r.Context(), webAuthnCeremonyAuthentication, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "new webauthn challenge failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+
+	config := utils.GetWebAuthnConfig()
+	options := WebAuthnAuthenticationOptions{
+		Challenge:        challenge,
+		RPID:             config.RPID,
+		Timeout:          config.Timeout.Milliseconds(),
+		AllowCredentials: allowCredentials,
+		UserVerification: "preferred",
+	}
+
+	utils.WriteJson(w, http.StatusOK, options)
+}
+
+// WebAuthnLoginFinish verifies the assertion and issues the same response as Login
+func WebAuthnLoginFinish(w http.ResponseWriter, r *http.Request) {
+	var req WebAuthnLoginRequest
+	// PublicKeyCredential.toJSON adds members this server doesn't use
+	// (clientExtensionResults, transports...), so unknown fields are allowed here
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	rawID, err := utils.DecodeWebAuthnBytes(req.RawID)
+	clientDataJSON, clientDataErr := utils.DecodeWebAuthnBytes(req.Response.ClientDataJSON)
+	authenticatorData, authDataErr := utils.DecodeWebAuthnBytes(req.Response.AuthenticatorData)
+	signature, signatureErr := utils.DecodeWebAuthnBytes(req.Response.Signature)
+	userHandle, userHandleErr := utils.DecodeWebAuthnBytes(req.Response.UserHandle)
+	if err != nil || clientDataErr != nil || authDataErr != nil || signatureErr != nil || userHandleErr != nil || req.Type != "public-key" {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid credential encoding")
+		return
+	}
+
+	challenge, sessionUserID, err := consumeWebAuthnChallenge(r.Context(), clientDataJSON, webAuthnCeremonyAuthentication)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "consume webauthn challenge failed", "error", err)
+		}
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Invalid or expired challenge")
+		return
+	}
+
+	credential, err := db.GetWebAuthnCredential(r.Context(), utils.EncodeWebAuthnBytes(rawID))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "get webauthn credential failed", "error", err)
+		}
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: unknown credential")
+		return
+	}
+
+	// credential must belong to the user the ceremony was started for
+	if sessionUserID != nil && *sessionUserID != credential.UserID {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: credential does not belong to user")
+		return
+	}
+	if len(userHandle) > 0 && !bytes.Equal(userHandle, webAuthnUserHandle(credential.UserID)) {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: user handle mismatch")
+		return
+	}
+
+	signCount, err := utils.VerifyWebAuthnAssertion(
+		challenge,
+		credential.PublicKey,
+		uint32(credential.SignCount),
+		clientDataJSON,
+		authenticatorData,
+		signature,
+	)
+	if err != nil {
+		slog.WarnContext(r.Context(), "webauthn assertion verification failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: assertion verification failed")
+		return
+	}
+
+	updated, err := db.UpdateWebAuthnSignCount(r.Context(), credential.ID, credential.SignCount, int64(signCount))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "update webauthn sign count failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		return
+	}
+	if !updated {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: concurrent use of credential")
+		return
+	}
+
+	user, err := db.GetUserByID(r.Context(), credential.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "get user by id failed", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Invalid credentials: user not found")
+		return
+	}
+
+	writeAuthResponse(w, r, user, utils.WantsCookieSession(r), "Login successful", http.StatusOK)
+}
@@ -0,0 +1,158 @@
+// Package health runs the readiness checks behind /status/ready.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check is a named dependency probe
+type Check struct {
+	Name    string
+	Check   func(ctx context.Context) error
+	Timeout time.Duration // default 2s
+	// non critical failures are reported but keep the instance ready, e.g. the
+	// mailer, so an SMTP outage doesn't take every replica out of the load balancer
+	Critical bool
+}
+
+// CheckResult is the reported outcome of a single check
+type CheckResult struct {
+	Status    string    `json:"status"` // "ok" or "fail"
+	Critical  bool      `json:"critical"`
+	LatencyMS float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the readiness response body
+type Report struct {
+	Status string                 `json:"status"` // "ready", "not_ready" or "shutting_down"
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type registeredCheck struct {
+	Check
+	mu     sync.Mutex // one probe at a time per check, concurrent requests share the result
+	result CheckResult
+	valid  time.Time
+}
+
+var (
+	checks       []*registeredCheck
+	checksMu     sync.RWMutex
+	cacheTTL     = 2 * time.Second
+	shuttingDown atomic.Bool
+)
+
+// Register adds a readiness check
+func Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = 2 * time.Second
+	}
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	checks = append(checks, &registeredCheck{Check: check})
+}
+
+// SetCacheTTL sets how long check results are reused between probes
+func SetCacheTTL(ttl time.Duration) {
+	cacheTTL = ttl
+}
+
+// SetShuttingDown makes readiness fail so load balancers stop sending new
+// requests while in flight ones are drained
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+func IsShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+func (c *registeredCheck) run(ctx context.Context) CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Now().Before(c.valid) {
+		return c.result
+	}
+
+	// the result is shared, so a probe client going away must not fail it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.Timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	err := c.Check.Check(ctx)
+	result := CheckResult{
+		Status:    "ok",
+		Critical:  c.Critical,
+		LatencyMS: float64(time.Since(startTime).Microseconds()) / 1000,
+		CheckedAt: startTime,
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+
+	c.result = result
+	c.valid = time.Now().Add(cacheTTL)
+	return result
+}
+
+// Run executes every check concurrently and builds the report
+func Run(ctx context.Context) Report {
+	checksMu.RLock()
+	registered := append([]*registeredCheck(nil), checks...)
+	checksMu.RUnlock()
+
+	results := make([]CheckResult, len(registered))
+	var wg sync.WaitGroup
+	for i, check := range registered {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: "ready", Checks: make(map[string]CheckResult, len(registered))}
+	for i, check := range registered {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != "ok" && check.Critical {
+			report.Status = "not_ready"
+		}
+	}
+	if IsShuttingDown() {
+		report.Status = "shutting_down"
+	}
+	return report
+}
+
+// LiveHandler reports that the process is up and serving, it never checks
+// dependencies so a database outage doesn't make the orchestrator restart pods
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
+}
+
+// ReadyHandler returns 200 when all critical checks pass, 503 otherwise
+func ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	report := Run(r.Context())
+
+	statusCode := http.StatusOK
+	if report.Status != "ready" {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,197 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resetHealth starts the test without registered checks and restores the
+// package state afterwards
+func resetHealth(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	checksMu.Lock()
+	previous := checks
+	checks = nil
+	checksMu.Unlock()
+	previousTTL := cacheTTL
+	cacheTTL = ttl
+
+	t.Cleanup(func() {
+		checksMu.Lock()
+		checks = previous
+		checksMu.Unlock()
+		cacheTTL = previousTTL
+		shuttingDown.Store(false)
+	})
+}
+
+func failing(err error) func(ctx context.Context) error {
+	return func(ctx context.Context) error { return err }
+}
+
+func passing(ctx context.Context) error { return nil }
+
+func ready(t *testing.T) (int, Report) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ReadyHandler(w, httptest.NewRequest(http.MethodGet, "/status/ready", nil))
+
+	var report Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, report
+}
+
+func TestReadyHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "no checks", wantCode: http.StatusOK, wantStatus: "ready"},
+		{
+			name:       "all passing",
+			checks:     []Check{{Name: "database", Check: passing, Critical: true}, {Name: "mailer", Check: passing}},
+			wantCode:   http.StatusOK,
+			wantStatus: "ready",
+		},
+		{
+			name:       "non critical failure",
+			checks:     []Check{{Name: "database", Check: passing, Critical: true}, {Name: "mailer", Check: failing(errors.New("smtp down"))}},
+			wantCode:   http.StatusOK,
+			wantStatus: "ready",
+		},
+		{
+			name:       "critical failure",
+			checks:     []Check{{Name: "database", Check: failing(errors.New("connection refused")), Critical: true}, {Name: "mailer", Check: passing}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "not_ready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetHealth(t, 0)
+			for _, check := range tt.checks {
+				Register(check)
+			}
+
+			code, report := ready(t)
+			if code != tt.wantCode || report.Status != tt.wantStatus {
+				t.Fatalf("ready = %d %q, want %d %q", code, report.Status, tt.wantCode, tt.wantStatus)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Fatalf("report has %d checks, want %d", len(report.Checks), len(tt.checks))
+			}
+			for _, check := range tt.checks {
+				result := report.Checks[check.Name]
+				wantErr := check.Check(context.Background())
+				if (result.Status == "ok") != (wantErr == nil) || result.Critical != check.Critical {
+					t.Errorf("check %s = %+v", check.Name, result)
+				}
+				if wantErr != nil && result.Error != wantErr.Error() {
+					t.Errorf("check %s error = %q, want %q", check.Name, result.Error, wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestChecksAreCached(t *testing.T) {
+	resetHealth(t, time.Hour)
+
+	var calls atomic.Int32
+	Register(Check{Name: "database", Critical: true, Check: func(ctx context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}})
+
+	// concurrent probes share one run and later ones reuse the result
+	done := make(chan struct{})
+	for range 5 {
+		go func() {
+			Run(context.Background())
+			done <- struct{}{}
+		}()
+	}
+	for range 5 {
+		<-done
+	}
+	Run(context.Background())
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("check ran %d times within the cache ttl, want 1", got)
+	}
+
+	// an expired result is probed again
+	checks[0].mu.Lock()
+	checks[0].valid = time.Now()
+	checks[0].mu.Unlock()
+	Run(context.Background())
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("check ran %d times after the cache expired, want 2", got)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	resetHealth(t, 0)
+
+	Register(Check{Name: "slow", Critical: true, Timeout: 20 * time.Millisecond, Check: func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}})
+	Register(Check{Name: "fast", Critical: true, Check: passing})
+
+	// a probe client going away does not fail the shared result
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	startTime := time.Now()
+	report := Run(ctx)
+	if elapsed := time.Since(startTime); elapsed > time.Second {
+		t.Fatalf("Run took %s, the check timeout is 20ms", elapsed)
+	}
+	if report.Status != "not_ready" || report.Checks["slow"].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("report = %+v, want the slow check to time out", report)
+	}
+	if report.Checks["fast"].Status != "ok" {
+		t.Errorf("fast check = %+v, the canceled request context must not fail it", report.Checks["fast"])
+	}
+}
+
+func TestShuttingDown(t *testing.T) {
+	resetHealth(t, 0)
+	Register(Check{Name: "database", Check: passing, Critical: true})
+
+	if code, report := ready(t); code != http.StatusOK || report.Status != "ready" {
+		t.Fatalf("ready = %d %q before shutdown", code, report.Status)
+	}
+
+	SetShuttingDown()
+	code, report := ready(t)
+	if code != http.StatusServiceUnavailable || report.Status != "shutting_down" {
+		t.Fatalf("ready = %d %q, want 503 shutting_down", code, report.Status)
+	}
+	if report.Checks["database"].Status != "ok" {
+		t.Errorf("checks are still reported while shutting down, got %+v", report.Checks)
+	}
+
+	// liveness is not affected
+	w := httptest.NewRecorder()
+	LiveHandler(w, httptest.NewRequest(http.MethodGet, "/status/live", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("live = %d while shutting down, want 200", w.Code)
+	}
+}
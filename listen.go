@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemd passes activated sockets starting at this descriptor
+const listenFDsStart = 3
+
+// listen opens the server listener, addr is a tcp address (":8000"),
+// "unix:/path/to/socket" or "fd:N" for an inherited descriptor.
+// Sockets passed by systemd socket activation (LISTEN_FDS) take precedence
+func listen(addr string) (net.Listener, error) {
+	listener, err := systemdListener()
+	if listener != nil || err != nil {
+		return listener, err
+	}
+
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		path := strings.TrimPrefix(addr, "unix:")
+		// a socket left over by a crashed process would fail the bind
+		if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+			os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	case strings.HasPrefix(addr, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(addr, "fd:"))
+		if err != nil || fd < listenFDsStart {
+			return nil, fmt.Errorf("invalid listen descriptor %q", addr)
+		}
+		return fileListener(fd, addr)
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+// systemdListener returns the first socket passed by systemd, nil when
+// the process was not socket activated
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+	if count > 1 {
+		return nil, errors.New("only one systemd socket is supported")
+	}
+
+	// don't pass the sockets on to child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	return fileListener(listenFDsStart, "systemd")
+}
+
+func fileListener(fd int, name string) (net.Listener, error) {
+	file := os.NewFile(uintptr(fd), name)
+	if file == nil {
+		return nil, fmt.Errorf("invalid listen descriptor %d", fd)
+	}
+	// FileListener dups the descriptor
+	defer file.Close()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("listen on descriptor %d: %w", fd, err)
+	}
+	return listener, nil
+}
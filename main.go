@@ -1,50 +1,108 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/olksndrdevhub/go-api-starter-kit/config"
 	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
 	"github.com/olksndrdevhub/go-api-starter-kit/utils"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("WARNING: .env file not found")
+	envErr := godotenv.Load()
+
+	// serve is the default, flags may follow the binary name directly
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage(os.Stdout)
+		return
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		os.Exit(2)
 	}
 
-	jwtSecret := utils.GetEnv("JWT_SECRET", "secret")
-	utils.SetJWTSecretKey([]byte(jwtSecret))
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0])+" "+cmd.name, flag.ContinueOnError)
+	run := cmd.setup(fs)
+	cfg, err := config.Load(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
 
-	dbConfig := db.DBConfig{
-		Host:     utils.GetEnv("DB_HOST", "localhost"),
-		Port:     utils.GetEnv("DB_PORT", "5432"), // PostgreSQL default port
-		User:     utils.GetEnv("DB_USER", "postgres"),
-		Password: utils.GetEnv("DB_PASSWORD", ""),
-		DBName:   utils.GetEnv("DB_NAME", "app"),
-		SSLMode:  utils.GetEnv("DB_SSL_MODE", "disable"), // For PostgreSQL
+	// structured logs, json lines by default
+	utils.SetupLogger(utils.LogConfig{
+		Format: cfg.Log.Format,
+		Level:  utils.ParseLogLevel(cfg.Log.Level),
+	})
+	if envErr != nil && cmd.name == "serve" {
+		slog.Warn(".env file not found")
 	}
 
-	// Create database instance
-	err = db.InitDB(dbConfig)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	if cmd.validate {
+		for _, warning := range cfg.Warnings {
+			slog.Warn(warning)
+		}
+		// fail fast, a misconfigured deploy should not start serving
+		if err := cfg.Validate(); err != nil {
+			slog.Error("invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		if cfg.Server.WriteTimeout > 0 && cfg.Server.HandlerTimeout >= cfg.Server.WriteTimeout {
+			slog.Warn("HANDLER_TIMEOUT should be lower than SERVER_WRITE_TIMEOUT, clients may get no response on timeout")
+		}
 	}
-	defer db.DB.Close()
 
-	handler := SetupRouters()
+	if cmd.needsDB {
+		if err := configure(cfg); err != nil {
+			slog.Error("failed to configure", "error", err)
+			os.Exit(1)
+		}
+
+		// Create database instance
+		err = db.InitDB(dbConfig(cfg))
+		if err != nil {
+			slog.Error("failed to initialize database", "error", err)
+			os.Exit(1)
+		}
+	}
 
-	port := utils.GetEnv("PORT", "8000")
-	addr := ":" + port
-	log.Printf("Server is starting on %s...\n", addr)
+	err = run(context.Background(), cfg)
 
-	server := http.Server{
-		Addr:    addr,
-		Handler: handler,
+	if cmd.needsDB {
+		runShutdown([]shutdownStep{
+			{name: "tracing", timeout: 10 * time.Second, stop: tracing.Shutdown},
+			// last, every step before may still need it
+			{name: "database", timeout: 5 * time.Second, stop: func(ctx context.Context) error {
+				return db.Close()
+			}},
+		})
 	}
 
-	log.Fatal(server.ListenAndServe())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"database/sql"
+	"runtime"
+	"time"
+)
+
+var processStartTime = time.Now()
+
+func init() {
+	NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	NewGaugeFunc("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", func() float64 {
+		return float64(processStartTime.UnixNano()) / 1e9
+	})
+}
+
+// RegisterDBStats exposes the database/sql connection pool stats, stats is
+// called on every scrape so it always reads the current pool
+func RegisterDBStats(stats func() sql.DBStats) {
+	NewGaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.", func() float64 {
+		return float64(stats().MaxOpenConnections)
+	})
+	NewGaugeFunc("db_open_connections", "The number of established connections both in use and idle.", func() float64 {
+		return float64(stats().OpenConnections)
+	})
+	NewGaugeFunc("db_in_use_connections", "The number of connections currently in use.", func() float64 {
+		return float64(stats().InUse)
+	})
+	NewGaugeFunc("db_idle_connections", "The number of idle connections.", func() float64 {
+		return float64(stats().Idle)
+	})
+	NewCounterFunc("db_wait_count_total", "The total number of connections waited for.", func() float64 {
+		return float64(stats().WaitCount)
+	})
+	NewCounterFunc("db_wait_duration_seconds_total", "The total time blocked waiting for a new connection.", func() float64 {
+		return stats().WaitDuration.Seconds()
+	})
+	NewCounterFunc("db_max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns.", func() float64 {
+		return float64(stats().MaxIdleClosed)
+	})
+	NewCounterFunc("db_max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime.", func() float64 {
+		return float64(stats().MaxIdleTimeClosed)
+	})
+	NewCounterFunc("db_max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime.", func() float64 {
+		return float64(stats().MaxLifetimeClosed)
+	})
+}
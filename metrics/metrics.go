@@ -0,0 +1,389 @@
+// Package metrics implements the counters, gauges and histograms the app
+// exposes in the Prometheus text format, without a client library dependency.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are latency buckets in seconds, same as the Prometheus client defaults
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector writes its metric families in text format
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds collectors and renders them on scrape
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]collector{}}
+}
+
+// DefaultRegistry is used by the New* constructors and Handler
+var DefaultRegistry = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[c.name()]; exists {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteText renders every metric sorted by name
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		DefaultRegistry.WriteText(w)
+	})
+}
+
+// atomicFloat is a float64 updated with compare and swap
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) Add(delta float64) {
+	for {
+		old := f.bits.Load()
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if f.bits.CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Set(value float64) {
+	f.bits.Store(math.Float64bits(value))
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+// family keeps one series per label value combination
+type family[T any] struct {
+	metricName string
+	help       string
+	labels     []string
+	mu         sync.RWMutex
+	series     map[string]*T
+	values     map[string][]string
+	newSeries  func() *T
+}
+
+func (f *family[T]) name() string {
+	return f.metricName
+}
+
+func (f *family[T]) with(labelValues []string) *T {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.metricName, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.RLock()
+	series, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return series
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if series, ok = f.series[key]; ok {
+		return series
+	}
+	series = f.newSeries()
+	f.series[key] = series
+	f.values[key] = append([]string(nil), labelValues...)
+	return series
+}
+
+// each calls fn for every series sorted by label values
+func (f *family[T]) each(fn func(labelValues []string, series *T)) {
+	f.mu.RLock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	f.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f.mu.RLock()
+		series, values := f.series[key], f.values[key]
+		f.mu.RUnlock()
+		fn(values, series)
+	}
+}
+
+func (f *family[T]) writeHeader(w io.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, metricType)
+}
+
+func newFamily[T any](name, help string, labels []string, newSeries func() *T) *family[T] {
+	return &family[T]{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		series:     map[string]*T{},
+		values:     map[string][]string{},
+		newSeries:  newSeries,
+	}
+}
+
+// Counter only goes up
+type Counter struct {
+	value atomicFloat
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.value.Add(delta)
+}
+
+type CounterVec struct {
+	*family[Counter]
+}
+
+// NewCounterVec registers a counter with the given label names in the default registry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newFamily(name, help, labels, func() *Counter { return &Counter{} })}
+	DefaultRegistry.register(c)
+	return c
+}
+
+func (c *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return c.with(labelValues)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.writeHeader(w, "counter")
+	c.each(func(labelValues []string, counter *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, formatLabels(c.labels, labelValues), formatValue(counter.value.Load()))
+	})
+}
+
+// Gauge can go up and down
+type Gauge struct {
+	value atomicFloat
+}
+
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+func (g *Gauge) Set(value float64) {
+	g.value.Set(value)
+}
+
+type GaugeVec struct {
+	*family[Gauge]
+}
+
+// NewGaugeVec registers a gauge with the given label names in the default registry
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newFamily(name, help, labels, func() *Gauge { return &Gauge{} })}
+	DefaultRegistry.register(g)
+	return g
+}
+
+func (g *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return g.with(labelValues)
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.writeHeader(w, "gauge")
+	g.each(func(labelValues []string, gauge *Gauge) {
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, formatLabels(g.labels, labelValues), formatValue(gauge.value.Load()))
+	})
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomicFloat
+}
+
+func (h *Histogram) Observe(value float64) {
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			h.counts[i].Add(1)
+		}
+	}
+	h.count.Add(1)
+	h.sum.Add(value)
+}
+
+type HistogramVec struct {
+	*family[Histogram]
+}
+
+// NewHistogramVec registers a histogram in the default registry, nil buckets use DefaultBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{newFamily(name, help, labels, func() *Histogram {
+		return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets))}
+	})}
+	DefaultRegistry.register(h)
+	return h
+}
+
+func (h *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	return h.with(labelValues)
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.writeHeader(w, "histogram")
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	h.each(func(labelValues []string, histogram *Histogram) {
+		withBound := func(le string) []string {
+			return append(append([]string(nil), labelValues...), le)
+		}
+		for i, upperBound := range histogram.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(bucketLabels, withBound(formatValue(upperBound))), histogram.counts[i].Load())
+		}
+		count := histogram.count.Load()
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(bucketLabels, withBound("+Inf")), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, labelValues), formatValue(histogram.sum.Load()))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, labelValues), count)
+	})
+}
+
+// GaugeFunc reads its value at scrape time
+type GaugeFunc struct {
+	metricName string
+	help       string
+	fn         func() float64
+}
+
+// NewGaugeFunc registers a gauge computed by fn on every scrape
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, fn: fn}
+	DefaultRegistry.register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.metricName, escapeHelp(g.help))
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.metricName)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatValue(g.fn()))
+}
+
+// CounterFunc reads a monotonically increasing value at scrape time
+type CounterFunc struct {
+	GaugeFunc
+}
+
+// NewCounterFunc registers a counter computed by fn on every scrape
+func NewCounterFunc(name, help string, fn func() float64) *CounterFunc {
+	c := &CounterFunc{GaugeFunc{metricName: name, help: help, fn: fn}}
+	DefaultRegistry.register(c)
+	return c
+}
+
+func (c *CounterFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", c.metricName, escapeHelp(c.help))
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.metricName)
+	fmt.Fprintf(w, "%s %s\n", c.metricName, formatValue(c.fn()))
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
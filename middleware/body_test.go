@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+func setRequestBodyConfig(t *testing.T, config utils.RequestBodyConfig) {
+	t.Helper()
+	previous := utils.GetRequestBodyConfig()
+	utils.SetRequestBodyConfig(config)
+	t.Cleanup(func() { utils.SetRequestBodyConfig(previous) })
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readBodyHandler answers with the number of body bytes it could read
+var readBodyHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if r.Header.Get("Content-Encoding") != "" {
+		http.Error(w, "Content-Encoding was not removed", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(strconv.Itoa(len(data))))
+})
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	// compresses to a few hundred bytes
+	bomb := gzipBytes(t, bytes.Repeat([]byte("a"), 1<<20))
+
+	tests := []struct {
+		name          string
+		config        utils.RequestBodyConfig
+		routeLimit    int64 // BodyLimit of the route, 0 for none
+		body          []byte
+		encoding      string
+		unknownLength bool
+		wantStatus    int
+		wantBody      string
+	}{
+		{name: "under the limit", config: utils.RequestBodyConfig{MaxBytes: 10}, body: []byte("12345"), wantStatus: http.StatusOK, wantBody: "5"},
+		{name: "exactly the limit", config: utils.RequestBodyConfig{MaxBytes: 10}, body: []byte("1234567890"), wantStatus: http.StatusOK, wantBody: "10"},
+		{name: "exactly the limit, unknown length", config: utils.RequestBodyConfig{MaxBytes: 10}, body: []byte("1234567890"), unknownLength: true, wantStatus: http.StatusOK, wantBody: "10"},
+		{name: "declared length over the limit", config: utils.RequestBodyConfig{MaxBytes: 10}, body: []byte("12345678901"), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "streamed past the limit", config: utils.RequestBodyConfig{MaxBytes: 10}, body: []byte("12345678901"), unknownLength: true, wantStatus: http.StatusRequestEntityTooLarge},
+
+		{name: "route raises the limit", config: utils.RequestBodyConfig{MaxBytes: 10}, routeLimit: 100, body: bytes.Repeat([]byte("a"), 50), wantStatus: http.StatusOK, wantBody: "50"},
+		{name: "route raises the limit, unknown length", config: utils.RequestBodyConfig{MaxBytes: 10}, routeLimit: 100, body: bytes.Repeat([]byte("a"), 50), unknownLength: true, wantStatus: http.StatusOK, wantBody: "50"},
+		{name: "route lowers the limit", config: utils.RequestBodyConfig{MaxBytes: 100}, routeLimit: 10, body: bytes.Repeat([]byte("a"), 50), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "route lowers the limit, unknown length", config: utils.RequestBodyConfig{MaxBytes: 100}, routeLimit: 10, body: bytes.Repeat([]byte("a"), 50), unknownLength: true, wantStatus: http.StatusRequestEntityTooLarge},
+
+		{name: "gzip body is decoded", config: utils.RequestBodyConfig{MaxBytes: 1 << 10, AllowGzip: true}, body: gzipBytes(t, []byte(`{"ok":true}`)), encoding: "gzip", wantStatus: http.StatusOK, wantBody: "11"},
+		{name: "x-gzip body is decoded", config: utils.RequestBodyConfig{MaxBytes: 1 << 10, AllowGzip: true}, body: gzipBytes(t, []byte(`{"ok":true}`)), encoding: "X-Gzip", wantStatus: http.StatusOK, wantBody: "11"},
+		{name: "gzip bomb", config: utils.RequestBodyConfig{MaxBytes: 64 << 10, AllowGzip: true}, body: bomb, encoding: "gzip", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "gzip bomb under a raised route limit", config: utils.RequestBodyConfig{MaxBytes: 1 << 10, AllowGzip: true}, routeLimit: 64 << 10, body: bomb, encoding: "gzip", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "gzip disabled", config: utils.RequestBodyConfig{MaxBytes: 1 << 10}, body: gzipBytes(t, []byte("{}")), encoding: "gzip", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "invalid gzip", config: utils.RequestBodyConfig{MaxBytes: 1 << 10, AllowGzip: true}, body: []byte("not gzip at all"), encoding: "gzip", wantStatus: http.StatusBadRequest},
+		{name: "unsupported encoding", config: utils.RequestBodyConfig{MaxBytes: 1 << 10, AllowGzip: true}, body: []byte("{}"), encoding: "br", wantStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequestBodyConfig(t, tt.config)
+
+			handler := http.Handler(readBodyHandler)
+			if tt.routeLimit > 0 {
+				handler = BodyLimit(tt.routeLimit)(handler)
+			}
+			handler = BodyLimitMiddleware(handler)
+
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			if tt.unknownLength {
+				r.ContentLength = -1
+			}
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("handler read %s bytes, want %s", w.Body, tt.wantBody)
+			}
+			if w.Code == http.StatusRequestEntityTooLarge && !strings.Contains(w.Header().Get("Content-Type"), "problem+json") {
+				t.Errorf("413 content type = %q, want a problem response", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	handler := RequireContentType("application/json", "application/x-www-form-urlencoded")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        int
+	}{
+		{"json", "{}", "application/json", http.StatusOK},
+		{"json with charset", "{}", "Application/JSON; charset=utf-8", http.StatusOK},
+		{"form", "a=b", "application/x-www-form-urlencoded", http.StatusOK},
+		{"no body", "", "", http.StatusOK},
+		{"missing content type", "{}", "", http.StatusUnsupportedMediaType},
+		{"other content type", "{}", "text/plain", http.StatusUnsupportedMediaType},
+		{"invalid content type", "{}", "application/", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusUnsupportedMediaType && w.Header().Get("Accept-Post") == "" {
+				t.Error("415 without Accept-Post")
+			}
+		})
+	}
+}
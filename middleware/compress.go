@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// gzipWriterPools reuses writers per compression level, a gzip.Writer
+// allocates several hundred KB of state
+var gzipWriterPools sync.Map
+
+func getGzipWriter(w io.Writer, level int) *gzip.Writer {
+	pool, _ := gzipWriterPools.LoadOrStore(level, &sync.Pool{})
+	if gw, ok := pool.(*sync.Pool).Get().(*gzip.Writer); ok {
+		gw.Reset(w)
+		return gw
+	}
+	gw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		gw = gzip.NewWriter(w)
+	}
+	return gw
+}
+
+func putGzipWriter(gw *gzip.Writer, level int) {
+	pool, _ := gzipWriterPools.Load(level)
+	pool.(*sync.Pool).Put(gw)
+}
+
+// compressWriter buffers the start of the response until it knows whether
+// compressing is worth it: MinSize bytes were written, the handler flushed
+// or the handler returned
+type compressWriter struct {
+	http.ResponseWriter
+	config      utils.CompressionConfig
+	buf         []byte
+	code        int
+	wroteHeader bool // the handler called WriteHeader or Write
+	decided     bool // headers were sent to the client
+	gw          *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	// informational responses like 103 Early Hints pass through
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.wroteHeader = true
+	cw.code = code
+	if !bodyAllowed(code) || cw.Header().Get("Content-Encoding") != "" || !compressibleType(cw.Header().Get("Content-Type")) {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			// sniff now, the compressed bytes would be detected as gzip
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.gw != nil {
+			return cw.gw.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.config.MinSize {
+		// a declared small Content-Length is respected as well
+		length, err := strconv.Atoi(cw.Header().Get("Content-Length"))
+		cw.decide(err != nil || length >= cw.config.MinSize)
+		if err := cw.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide sends the headers, with gzip when compress is true
+func (cw *compressWriter) decide(compress bool) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+	if compress {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(cw.buf))
+		}
+		cw.Header().Set("Content-Encoding", "gzip")
+		cw.Header().Del("Content-Length")
+		// strong validators must change with the representation
+		if etag := cw.Header().Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			cw.Header().Set("ETag", "W/"+etag)
+		}
+		cw.gw = getGzipWriter(cw.ResponseWriter, cw.config.Level)
+	}
+	if cw.code == 0 {
+		cw.code = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.code)
+}
+
+func (cw *compressWriter) flushBuffer() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.gw != nil {
+		_, err = cw.gw.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+// Flush compresses what was written so far, streaming responses like
+// server-sent events keep working
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if cw.decided {
+		cw.flushBuffer()
+		if cw.gw != nil {
+			cw.gw.Flush()
+		}
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack is used by websocket upgrades, they are never compressed
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close sends what is still buffered, small bodies go out uncompressed
+func (cw *compressWriter) close() {
+	if cw.wroteHeader && !cw.decided {
+		cw.decide(false)
+	}
+	cw.flushBuffer()
+	if cw.gw != nil {
+		cw.gw.Close()
+		putGzipWriter(cw.gw, cw.config.Level)
+		cw.gw = nil
+	}
+}
+
+// CompressMiddleware gzips responses for clients that accept it. Bodies
+// smaller than MinSize, already encoded responses and compressed formats
+// like images are sent as is. Only gzip is offered, brotli and zstd have no
+// encoder in the standard library
+func CompressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := utils.GetCompressionConfig()
+		if !config.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// caches must not hand a gzipped response to clients that can't read it
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, config: config}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptsGzip parses Accept-Encoding, e.g. "gzip, deflate, br" or "*;q=0.5, gzip;q=0"
+func acceptsGzip(header string) bool {
+	accepted := false
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if coding == "gzip" {
+			// an explicit gzip entry wins over the wildcard
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// compressibleType skips formats that are compressed already
+func compressibleType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
+
+func bodyAllowed(code int) bool {
+	return code != http.StatusNoContent && code != http.StatusNotModified && code >= 200
+}
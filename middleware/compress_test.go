@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"gzip, deflate, br", true},
+		{"deflate, br", false},
+		{"identity", false},
+		{"*", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.5", true},
+		{"gzip;q=0.0", false},
+		{"*;q=0", false},
+		{"*;q=0.5, gzip;q=0", false},
+		{"gzip;q=0, *", false},
+		{"*;q=0, gzip", true},
+		{"br, *;q=0.1", true},
+		{"gzip;q=bogus", true},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func setCompressionConfig(t *testing.T, config utils.CompressionConfig) {
+	t.Helper()
+	previous := utils.GetCompressionConfig()
+	utils.SetCompressionConfig(config)
+	t.Cleanup(func() { utils.SetCompressionConfig(previous) })
+}
+
+// decodeBody returns the response body, gunzipped when it is gzip encoded
+func decodeBody(t *testing.T, header http.Header, body io.Reader) string {
+	t.Helper()
+	if header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatalf("gzip response: %v", err)
+		}
+		body = gr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	setCompressionConfig(t, utils.CompressionConfig{Enabled: true, Level: 5, MinSize: 100})
+	large := strings.Repeat(`{"name":"value"}`, 20)
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		requestHeaders map[string]string
+		handler        http.HandlerFunc
+		wantGzip       bool
+		wantStatus     int
+		wantBody       string
+		wantETag       string
+	}{
+		{
+			name: "large json is compressed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(large))
+			},
+			wantGzip: true,
+			wantBody: large,
+		},
+		{
+			name: "written in small pieces",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				for _, c := range large {
+					w.Write([]byte{byte(c)})
+				}
+			},
+			wantGzip: true,
+			wantBody: large,
+		},
+		{
+			name: "under the min size",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"ok":true}`))
+			},
+			wantBody: `{"ok":true}`,
+		},
+		{
+			name: "small declared content length",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Length", "50")
+				w.Write([]byte(large[:50]))
+				w.Write([]byte(large[50:150]))
+			},
+			wantBody: large[:150],
+		},
+		{
+			name: "already compressed type",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.Write([]byte(large))
+			},
+			wantBody: large,
+		},
+		{
+			name: "sniffed type",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("<html><body>" + large + "</body></html>"))
+			},
+			wantGzip: true,
+			wantBody: "<html><body>" + large + "</body></html>",
+		},
+		{
+			name: "already encoded by the handler",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Encoding", "br")
+				w.Write([]byte(large))
+			},
+			wantBody: large,
+		},
+		{
+			name: "no content status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "status is kept",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(large))
+			},
+			wantGzip:   true,
+			wantStatus: http.StatusCreated,
+			wantBody:   large,
+		},
+		{
+			name: "strong etag becomes weak",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"v1"`)
+				w.Write([]byte(large))
+			},
+			wantGzip: true,
+			wantBody: large,
+			wantETag: `W/"v1"`,
+		},
+		{
+			name: "etag of uncompressed response is kept",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"v1"`)
+				w.Write([]byte(`{}`))
+			},
+			wantBody: `{}`,
+			wantETag: `"v1"`,
+		},
+		{
+			name:           "client does not accept gzip",
+			acceptEncoding: "br",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(large))
+			},
+			wantBody: large,
+		},
+		{
+			name:   "head passes through",
+			method: http.MethodHead,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "320")
+			},
+		},
+		{
+			name:           "range passes through",
+			requestHeaders: map[string]string{"Range": "bytes=0-9"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				http.ServeContent(w, r, "data.txt", time.Time{}, strings.NewReader(large))
+			},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   large[:10],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			acceptEncoding := tt.acceptEncoding
+			if acceptEncoding == "" {
+				acceptEncoding = "gzip, br"
+			}
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+			for name, value := range tt.requestHeaders {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			CompressMiddleware(tt.handler).ServeHTTP(w, r)
+
+			wantStatus := tt.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if w.Code != wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, wantStatus)
+			}
+			if gzipped := w.Header().Get("Content-Encoding") == "gzip"; gzipped != tt.wantGzip {
+				t.Fatalf("gzip = %v, want %v", gzipped, tt.wantGzip)
+			}
+			if tt.wantGzip && w.Header().Get("Content-Length") != "" {
+				t.Errorf("Content-Length %q sent with the compressed body", w.Header().Get("Content-Length"))
+			}
+			if !slices.Contains(w.Header().Values("Vary"), "Accept-Encoding") {
+				t.Errorf("Vary = %q, want Accept-Encoding", w.Header().Values("Vary"))
+			}
+			if body := decodeBody(t, w.Header(), w.Body); body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if tt.wantETag != "" && w.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), tt.wantETag)
+			}
+		})
+	}
+}
+
+func TestCompressMiddlewareFlush(t *testing.T) {
+	setCompressionConfig(t, utils.CompressionConfig{Enabled: true, Level: 5, MinSize: 1 << 10})
+
+	release := make(chan struct{})
+	server := httptest.NewServer(CompressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("data: second\n\n"))
+	})))
+	defer server.Close()
+	defer close(release)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	// keep the transport from decoding, the test reads the gzip stream itself
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("flushed stream is not compressed, Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first event arrives while the handler is still blocked
+	first := make([]byte, len("data: first\n\n"))
+	if _, err := io.ReadFull(gr, first); err != nil || string(first) != "data: first\n\n" {
+		t.Fatalf("first event = %q, %v", first, err)
+	}
+}
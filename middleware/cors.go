@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// CORSMiddleware applies utils.CORSConfig, preflight requests are answered
+// here, before auth middlewares reject them for missing credentials
+func CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		CORS(utils.GetCORSConfig())(next).ServeHTTP(w, r)
+	})
+}
+
+// CORS returns a middleware for a specific policy, e.g. for a route group
+// that needs a different one than the global CORSMiddleware
+func CORS(config utils.CORSConfig) Middleware {
+	allowedMethods := strings.Join(config.AllowedMethods, ", ")
+	exposedHeaders := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+	anyOrigin := slices.Contains(config.AllowedOrigins, "*")
+	anyHeader := slices.Contains(config.AllowedHeaders, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// responses depend on these, caches must not mix them up
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || len(config.AllowedOrigins) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed := utils.MatchOrigin(config.AllowedOrigins, origin)
+			if preflight {
+				// a rejected preflight gets no CORS headers, the browser blocks the request
+				if !allowed || !allowedMethod(config.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				requestHeaders := r.Header.Get("Access-Control-Request-Headers")
+				if !anyHeader && !allowedHeaders(config.AllowedHeaders, requestHeaders) {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+
+				setAllowOrigin(w, config, origin, anyOrigin)
+				w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+				if requestHeaders != "" {
+					// echo the checked request headers, this also covers "*" with credentials
+					w.Header().Set("Access-Control-Allow-Headers", requestHeaders)
+				}
+				if config.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if allowed {
+				setAllowOrigin(w, config, origin, anyOrigin)
+				if exposedHeaders != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setAllowOrigin(w http.ResponseWriter, config utils.CORSConfig, origin string, anyOrigin bool) {
+	if anyOrigin && !config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func allowedMethod(allowed []string, method string) bool {
+	return slices.ContainsFunc(allowed, func(m string) bool {
+		return strings.EqualFold(m, method)
+	})
+}
+
+// allowedHeaders checks every header of a comma separated Access-Control-Request-Headers
+func allowedHeaders(allowed []string, requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(allowed, func(h string) bool {
+			return strings.EqualFold(h, header)
+		}) {
+			return false
+		}
+	}
+	return true
+}
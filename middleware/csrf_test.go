@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+func TestSessionCookieCSRFMiddleware(t *testing.T) {
+	previous := utils.GetCookieConfig()
+	t.Cleanup(func() { utils.SetCookieConfig(previous) })
+	config := previous
+	config.Enabled = true
+	utils.SetCookieConfig(config)
+
+	tests := []struct {
+		name        string
+		method      string
+		cookieMode  bool
+		session     bool
+		csrfCookie  string
+		csrfHeader  string
+		contentType string
+		body        string
+		want        int
+	}{
+		{name: "bearer mode", method: http.MethodPost, session: true, want: http.StatusOK},
+		{name: "no session cookie", method: http.MethodPost, cookieMode: true, want: http.StatusOK},
+		{name: "safe method", method: http.MethodGet, cookieMode: true, session: true, want: http.StatusOK},
+		{name: "no csrf cookie", method: http.MethodPost, cookieMode: true, session: true, csrfHeader: "t", want: http.StatusForbidden},
+		{name: "no csrf header", method: http.MethodPost, cookieMode: true, session: true, csrfCookie: "t", want: http.StatusForbidden},
+		{name: "csrf mismatch", method: http.MethodPost, cookieMode: true, session: true, csrfCookie: "t", csrfHeader: "other", want: http.StatusForbidden},
+		{name: "csrf header", method: http.MethodPost, cookieMode: true, session: true, csrfCookie: "t", csrfHeader: "t", want: http.StatusOK},
+		{
+			name: "csrf form field", method: http.MethodPost, cookieMode: true, session: true, csrfCookie: "t",
+			contentType: "application/x-www-form-urlencoded", body: "csrf_token=t", want: http.StatusOK,
+		},
+	}
+
+	handler := SessionCookieCSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Enabled = tt.cookieMode
+			utils.SetCookieConfig(config)
+
+			r := httptest.NewRequest(tt.method, "/api/v1/auth/logout", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.session {
+				r.AddCookie(&http.Cookie{Name: config.Name, Value: "session"})
+			}
+			if tt.csrfCookie != "" {
+				r.AddCookie(&http.Cookie{Name: config.CSRFName, Value: tt.csrfCookie})
+			}
+			if tt.csrfHeader != "" {
+				r.Header.Set(config.CSRFHeader, tt.csrfHeader)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/metrics"
+)
+
+var (
+	httpRequestsTotal = metrics.NewCounterVec(
+		"http_requests_total",
+		"Total number of HTTP requests by route pattern, method and status code.",
+		"route", "method", "status",
+	)
+	httpRequestDuration = metrics.NewHistogramVec(
+		"http_request_duration_seconds",
+		"HTTP request latency by route pattern, method and status code.",
+		nil,
+		"route", "method", "status",
+	)
+	httpRequestsInFlight = metrics.NewGaugeVec(
+		"http_requests_in_flight",
+		"Number of HTTP requests currently being served.",
+	).WithLabelValues()
+	authLoginsTotal = metrics.NewCounterVec(
+		"auth_logins_total",
+		"Login attempts by login method and result (success, failure, error).",
+		"method", "result",
+	)
+	authTokenFailuresTotal = metrics.NewCounterVec(
+		"auth_token_validation_failures_total",
+		"Requests rejected by the authentication middleware by reason.",
+		"reason",
+	)
+	rateLimitedTotal = metrics.NewCounterVec(
+		"http_rate_limited_total",
+		"Requests rejected by rate limiting by policy.",
+		"policy",
+	)
+)
+
+// unmatchedRoute keeps label cardinality bounded for 404 scans
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware records request count, latency and in flight requests,
+// routes mounted with Mount are labeled by pattern instead of raw path
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		crw := &customResponseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+		startTime := time.Now()
+		r = r.WithContext(contextWithRoute(r.Context()))
+
+		next.ServeHTTP(crw, r)
+
+		route := GetRoute(r)
+		if route == "" {
+			route = unmatchedRoute
+		}
+		labels := []string{route, metricMethod(r.Method), strconv.Itoa(crw.statusCode)}
+		httpRequestsTotal.WithLabelValues(labels...).Inc()
+		httpRequestDuration.WithLabelValues(labels...).Observe(time.Since(startTime).Seconds())
+	})
+}
+
+// metricMethod folds non standard methods so clients can't create new series
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// LoginMetrics counts login outcomes for the wrapped login endpoint: 2xx is a
+// success, 401 a rejected credential and anything else an error
+func LoginMetrics(method string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			crw := &customResponseWriter{
+				ResponseWriter: w,
+				statusCode:     http.StatusOK,
+			}
+
+			next.ServeHTTP(crw, r)
+
+			result := "error"
+			if crw.statusCode < 300 {
+				result = "success"
+			} else if crw.statusCode == http.StatusUnauthorized {
+				result = "failure"
+			}
+			authLoginsTotal.WithLabelValues(method, result).Inc()
+		})
+	}
+}
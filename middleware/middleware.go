@@ -2,12 +2,15 @@ package middleware
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
 	"github.com/olksndrdevhub/go-api-starter-kit/utils"
 )
 
@@ -32,33 +35,257 @@ func CreateStuck(xs ...Middleware) Middleware {
 func JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
+		apiKeyHeader := r.Header.Get("X-API-Key")
+		authMethod := utils.AuthMethodBearer
 
-		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
+		var tokenString string
+		if apiKeyHeader != "" {
+			tokenString = apiKeyHeader
+			authMethod = utils.AuthMethodAPIKey
+		} else if authHeader != "" {
+			splitToken := strings.Split(authHeader, "Bearer ")
+			if len(splitToken) != 2 {
+				authTokenFailuresTotal.WithLabelValues("malformed").Inc()
+				utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidToken, "Invalid token format")
+				return
+			}
+			tokenString = splitToken[1]
+			if utils.IsAPIKey(tokenString) {
+				authMethod = utils.AuthMethodAPIKey
+			} else if utils.IsOAuthAccessToken(tokenString) {
+				authMethod = utils.AuthMethodOAuth
+			}
+		} else if identity, ok := GetClientIdentityFromContext(r); ok && utils.GetTLSConfig().ClientCertAuth && identity.Email() != "" {
+			tokenString = identity.Email()
+			authMethod = utils.AuthMethodClientCert
+		} else if cookieConfig := utils.GetCookieConfig(); cookieConfig.Enabled {
+			// fallback to session cookie for browser clients
+			cookie, err := r.Cookie(cookieConfig.Name)
+			if err != nil || cookie.Value == "" {
+				authTokenFailuresTotal.WithLabelValues("missing").Inc()
+				utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Authorization header or session cookie is missing")
+				return
+			}
+			tokenString = cookie.Value
+			authMethod = utils.AuthMethodCookie
+		} else {
+			authTokenFailuresTotal.WithLabelValues("missing").Inc()
+			utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeUnauthorized, "Authorization header is missing")
 			return
 		}
 
-		splitToken := strings.Split(authHeader, "Bearer ")
-		if len(splitToken) != 2 {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
+		ctx := r.Context()
+		if authMethod == utils.AuthMethodAPIKey {
+			apiKey, err := authenticateAPIKey(ctx, tokenString)
+			if err != nil {
+				authTokenFailuresTotal.WithLabelValues("invalid_api_key").Inc()
+				utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidToken, "Invalid or expired API key")
+				return
+			}
+			ctx = context.WithValue(ctx, utils.UserIDKey, apiKey.UserID)
+			ctx = context.WithValue(ctx, utils.ScopesKey, apiKey.Scopes)
+		} else if authMethod == utils.AuthMethodOAuth {
+			token, err := authenticateOAuthToken(ctx, tokenString)
+			if err != nil {
+				authTokenFailuresTotal.WithLabelValues("invalid_oauth_token").Inc()
+				utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidToken, "Invalid or expired access token")
+				return
+			}
+			ctx = context.WithValue(ctx, utils.UserIDKey, *token.UserID)
+			ctx = context.WithValue(ctx, utils.ScopesKey, token.Scopes)
+		} else if authMethod == utils.AuthMethodClientCert {
+			user, err := db.GetUserByEmail(ctx, tokenString)
+			if err != nil {
+				authTokenFailuresTotal.WithLabelValues("unknown_client_cert").Inc()
+				utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidCredentials, "Client certificate does not match a user")
+				return
+			}
+			ctx = context.WithValue(ctx, utils.UserIDKey, user.ID)
+			ctx = context.WithValue(ctx, utils.EmailKey, user.Email)
+		} else {
+			claims, err := utils.ValidateJWTToken(tokenString)
+			if errors.Is(err, utils.ErrTokenExpired) {
+				authTokenFailuresTotal.WithLabelValues("expired").Inc()
+				utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeTokenExpired, "Token expired")
+				return
+			}
+			if err != nil {
+				authTokenFailuresTotal.WithLabelValues("invalid").Inc()
+				utils.WriteProblem(w, r, http.StatusUnauthorized, utils.ErrCodeInvalidToken, "Invalid token")
+				return
+			}
+			ctx = context.WithValue(ctx, utils.UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, utils.EmailKey, claims.Email)
+		}
+		ctx = context.WithValue(ctx, utils.AuthMethodKey, authMethod)
+		utils.SetLogUserID(ctx, ctx.Value(utils.UserIDKey).(int64))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateAPIKey looks the key up on every request so revocation takes effect immediately
+func authenticateAPIKey(ctx context.Context, key string) (*db.APIKey, error) {
+	if !utils.IsAPIKey(key) {
+		return nil, errors.New("invalid api key format")
+	}
+
+	apiKey, err := db.GetAPIKeyByHash(ctx, utils.HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("api key expired")
+	}
+
+	err = db.TouchAPIKey(ctx, apiKey.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "touch api key failed", "error", err, "api_key_id", apiKey.ID)
+	}
+
+	return apiKey, nil
+}
+
+// authenticateOAuthToken looks the access token up on every request, revoked
+// tokens are deleted so revocation takes effect immediately. Client
+// credentials tokens have no user and can't call user endpoints
+func authenticateOAuthToken(ctx context.Context, tokenString string) (*db.OAuthToken, error) {
+	token, err := db.GetOAuthToken(ctx, utils.HashToken(tokenString))
+	if err != nil {
+		return nil, err
+	}
+	if token.TokenType != utils.OAuthTokenTypeAccess {
+		return nil, errors.New("not an access token")
+	}
+	if !token.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("access token expired")
+	}
+	if token.UserID == nil {
+		return nil, errors.New("access token has no user")
+	}
+	return token, nil
+}
+
+// ScopeMiddleware enforces API key and OAuth access token scopes: safe methods
+// need read or write scope, unsafe methods need write scope. API keys without
+// scopes and session tokens have full access, OAuth tokens always need a scope
+func ScopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, ok := GetScopesFromContext(r)
+		authMethod, _ := GetAuthMethodFromContext(r)
+		if authMethod != utils.AuthMethodOAuth && (!ok || len(scopes) == 0) {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		tokenString := splitToken[1]
+		allowed := slices.Contains(scopes, utils.APIKeyScopeWrite)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			allowed = allowed || slices.Contains(scopes, utils.APIKeyScopeRead)
+		}
 
-		claims, err := utils.ValidateJWTToken(tokenString)
-		if err != nil {
-			http.Error(w, "Invalid or expired token: "+err.Error(), http.StatusUnauthorized)
+		if !allowed {
+			if authMethod == utils.AuthMethodOAuth {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="read write"`)
+			}
+			utils.WriteProblem(w, r, http.StatusForbidden, utils.ErrCodeInsufficientScope, "Token scope does not allow this request")
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), utils.UserIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, utils.EmailKey, claims.Email)
+		next.ServeHTTP(w, r)
+	})
+}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+// RequireSessionAuth rejects API key and OAuth token authentication for
+// sensitive endpoints like managing API keys or changing password
+func RequireSessionAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch authMethod, _ := GetAuthMethodFromContext(r); authMethod {
+		case utils.AuthMethodAPIKey, utils.AuthMethodOAuth:
+			utils.WriteProblem(w, r, http.StatusForbidden, utils.ErrCodeForbidden, "This endpoint is not available with API key or access token authentication")
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
+// CSRFMiddleware protects unsafe methods of cookie authenticated requests
+// using the double-submit pattern, bearer token requests are not affected
+func CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authMethod, _ := GetAuthMethodFromContext(r)
+		if authMethod == utils.AuthMethodClientCert {
+			// browsers send client certificates on their own like cookies, but
+			// there is no csrf cookie, so reject cross site browser requests instead
+			switch r.Header.Get("Sec-Fetch-Site") {
+			case "", "same-origin", "none":
+				next.ServeHTTP(w, r)
+			default:
+				utils.WriteProblem(w, r, http.StatusForbidden, utils.ErrCodeCSRF, "Cross site request rejected")
+			}
+			return
+		}
+		if authMethod != utils.AuthMethodCookie || checkCSRFToken(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// SessionCookieCSRFMiddleware applies the double-submit check to requests that
+// carry the session cookie, for routes like logout that act on the cookie
+// without authenticating it
+func SessionCookieCSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookieConfig := utils.GetCookieConfig()
+		if !cookieConfig.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := r.Cookie(cookieConfig.Name); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if checkCSRFToken(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// checkCSRFToken compares the csrf cookie with the header or form field and
+// writes the problem response when they don't match
+func checkCSRFToken(w http.ResponseWriter, r *http.Request) bool {
+	cookieConfig := utils.GetCookieConfig()
+	csrfCookie, err := r.Cookie(cookieConfig.CSRFName)
+	if err != nil || csrfCookie.Value == "" {
+		utils.WriteProblem(w, r, http.StatusForbidden, utils.ErrCodeCSRF, "CSRF cookie is missing")
+		return false
+	}
+
+	csrfHeader := r.Header.Get(cookieConfig.CSRFHeader)
+	if csrfHeader == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		// html forms can't set headers, accept the token as a form field
+		csrfHeader = r.PostFormValue("csrf_token")
+	}
+	if csrfHeader == "" || subtle.ConstantTimeCompare([]byte(csrfHeader), []byte(csrfCookie.Value)) != 1 {
+		utils.WriteProblem(w, r, http.StatusForbidden, utils.ErrCodeCSRF, "Invalid CSRF token")
+		return false
+	}
+	return true
+}
+
 func GetUserIDFromContext(r *http.Request) (int64, bool) {
 	userID, ok := r.Context().Value(utils.UserIDKey).(int64)
 	return userID, ok
@@ -69,15 +296,38 @@ func GetEmailFromContext(r *http.Request) (string, bool) {
 	return email, ok
 }
 
+func GetAuthMethodFromContext(r *http.Request) (string, bool) {
+	authMethod, ok := r.Context().Value(utils.AuthMethodKey).(string)
+	return authMethod, ok
+}
+
+func GetScopesFromContext(r *http.Request) ([]string, bool) {
+	scopes, ok := r.Context().Value(utils.ScopesKey).([]string)
+	return scopes, ok
+}
+
 func (crw *customResponseWriter) WriteHeader(code int) {
 	crw.statusCode = code
 	crw.ResponseWriter.WriteHeader(code)
 }
+
+// Write counts the bytes sent to the client, compressed ones when
+// CompressMiddleware runs inside
 func (crw *customResponseWriter) Write(b []byte) (int, error) {
 	size, err := crw.ResponseWriter.Write(b)
 	crw.responseSize += size
 	return size, err
 }
+
+// Flush keeps streaming responses working through the wrapper
+func (crw *customResponseWriter) Flush() {
+	http.NewResponseController(crw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (crw *customResponseWriter) Unwrap() http.ResponseWriter {
+	return crw.ResponseWriter
+}
 func LogsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		crw := &customResponseWriter{
@@ -86,41 +336,28 @@ func LogsMiddleware(next http.Handler) http.Handler {
 		}
 
 		startTime := time.Now()
-		requestID := fmt.Sprintf("%d", time.Now().UnixNano())
+		// lets the auth middleware report the user id back to the access log
+		ctx := utils.ContextWithLogFields(r.Context())
 
-		method := r.Method
-		path := r.URL.Path
-		clientIP := r.RemoteAddr
-		userAgent := r.UserAgent()
-		referer := r.Referer()
+		next.ServeHTTP(crw, r.WithContext(ctx))
 
-		next.ServeHTTP(crw, r)
-
-		duration := time.Since(startTime)
 		statusCode := crw.statusCode
-		statusText := http.StatusText(statusCode)
-
-		// Create structured log entry
-		logEntry := fmt.Sprintf(
-			"request_id=%s method=%s path=%s status=%d status_text=%s duration=%v ip=%s size=%d user_agent=%q referer=%q",
-			requestID,
-			method,
-			path,
-			statusCode,
-			statusText,
-			duration,
-			clientIP,
-			crw.responseSize,
-			userAgent,
-			referer,
-		)
-
+		level := slog.LevelInfo
 		if statusCode >= 500 {
-			log.Printf("ERROR %s", logEntry)
+			level = slog.LevelError
 		} else if statusCode >= 400 {
-			log.Printf("WARN %s", logEntry)
-		} else {
-			log.Printf("INFO %s", logEntry)
+			level = slog.LevelWarn
 		}
+
+		slog.LogAttrs(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", statusCode),
+			slog.Float64("duration_ms", float64(time.Since(startTime).Microseconds())/1000),
+			slog.String("ip", GetClientIP(r)),
+			slog.Int("size", crw.responseSize),
+			slog.String("user_agent", r.UserAgent()),
+			slog.String("referer", r.Referer()),
+		)
 	})
 }
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// ClientIPMiddleware resolves the client ip, scheme and host into the request
+// context. Forwarded, X-Forwarded-For/Proto/Host and X-Real-IP are only read
+// when the socket peer is a trusted proxy, otherwise clients could spoof them
+func ClientIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := resolveClientInfo(r, utils.GetProxyConfig())
+		ctx := context.WithValue(r.Context(), utils.ClientInfoKey, info)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func GetClientInfoFromContext(r *http.Request) (utils.ClientInfo, bool) {
+	info, ok := r.Context().Value(utils.ClientInfoKey).(utils.ClientInfo)
+	return info, ok
+}
+
+// GetClientIP returns the resolved client ip, or the socket peer when
+// ClientIPMiddleware did not run
+func GetClientIP(r *http.Request) string {
+	if info, ok := GetClientInfoFromContext(r); ok {
+		return info.IP
+	}
+	return peerIP(r.RemoteAddr)
+}
+
+// forwardedHop is one proxy hop, the address it received the request from
+type forwardedHop struct {
+	forAddr string
+	proto   string
+	host    string
+}
+
+func resolveClientInfo(r *http.Request, config utils.ProxyConfig) utils.ClientInfo {
+	info := utils.ClientInfo{IP: peerIP(r.RemoteAddr), Scheme: "http", Host: r.Host}
+	if r.TLS != nil {
+		info.Scheme = "https"
+	}
+	if !trustedPeer(r, config) {
+		return info
+	}
+
+	// Forwarded is the standard, the X- headers are only used without it
+	hops := forwardedHeaderHops(r.Header)
+	if len(hops) == 0 {
+		hops = xForwardedHops(r.Header)
+	}
+	if len(hops) == 0 {
+		if addr, ok := parseNodeAddr(r.Header.Get("X-Real-IP")); ok {
+			info.IP = addr.String()
+		}
+		return info
+	}
+
+	// walk from the nearest proxy towards the client, the first address
+	// that is not a trusted proxy is the client
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseNodeAddr(hops[i].forAddr)
+		if !ok {
+			// "unknown" and obfuscated identifiers end the chain
+			break
+		}
+		info.IP = addr.String()
+		if proto := strings.ToLower(hops[i].proto); proto == "http" || proto == "https" {
+			info.Scheme = proto
+		}
+		if host := hops[i].host; host != "" && !strings.ContainsAny(host, " /\\@") {
+			info.Host = host
+		}
+		if !config.Trusts(addr) {
+			break
+		}
+	}
+	return info
+}
+
+func trustedPeer(r *http.Request, config utils.ProxyConfig) bool {
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return config.Trusts(addrPort.Addr())
+	}
+	localAddr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && localAddr.Network() == "unix" && config.TrustUnixSocket
+}
+
+// forwardedHeaderHops parses RFC 7239 Forwarded: for=192.0.2.60;proto=https, for="[2001:db8::1]"
+func forwardedHeaderHops(header http.Header) []forwardedHop {
+	var hops []forwardedHop
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			var hop forwardedHop
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				val = strings.Trim(val, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					hop.forAddr = val
+				case "proto":
+					hop.proto = val
+				case "host":
+					hop.host = val
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// xForwardedHops parses X-Forwarded-For, proxies usually overwrite
+// X-Forwarded-Proto and X-Forwarded-Host so they belong to the nearest hop
+func xForwardedHops(header http.Header) []forwardedHop {
+	var hops []forwardedHop
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(value, ",") {
+			hops = append(hops, forwardedHop{forAddr: strings.TrimSpace(addr)})
+		}
+	}
+	if len(hops) > 0 {
+		hops[len(hops)-1].proto = lastListValue(header.Get("X-Forwarded-Proto"))
+		hops[len(hops)-1].host = lastListValue(header.Get("X-Forwarded-Host"))
+	}
+	return hops
+}
+
+func lastListValue(value string) string {
+	return strings.TrimSpace(value[strings.LastIndex(value, ",")+1:])
+}
+
+// parseNodeAddr parses an address with optional port, ipv6 may be in brackets
+func parseNodeAddr(node string) (netip.Addr, bool) {
+	node = strings.TrimSpace(node)
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(node, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// peerIP strips the port, unix socket peers keep their address as is
+func peerIP(remoteAddr string) string {
+	if addrPort, err := netip.ParseAddrPort(remoteAddr); err == nil {
+		return addrPort.Addr().Unmap().String()
+	}
+	return remoteAddr
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+func TestParseNodeAddr(t *testing.T) {
+	tests := []struct {
+		node   string
+		want   string
+		wantOK bool
+	}{
+		{"192.0.2.60", "192.0.2.60", true},
+		{" 192.0.2.60 ", "192.0.2.60", true},
+		{"192.0.2.60:4711", "192.0.2.60", true},
+		{"2001:db8::1", "2001:db8::1", true},
+		{"[2001:db8::1]", "2001:db8::1", true},
+		{"[2001:db8::1]:4711", "2001:db8::1", true},
+		{"::ffff:192.0.2.60", "192.0.2.60", true},
+		{"unknown", "", false},
+		{"_hidden", "", false},
+		{"", "", false},
+		{"example.com:80", "", false},
+	}
+	for _, tt := range tests {
+		addr, ok := parseNodeAddr(tt.node)
+		if ok != tt.wantOK || (ok && addr.String() != tt.want) {
+			t.Errorf("parseNodeAddr(%q) = %s, %v, want %s, %v", tt.node, addr, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestResolveClientInfo(t *testing.T) {
+	config, err := utils.ParseTrustedProxies([]string{"10.0.0.0/8", "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		unix       bool
+		tls        bool
+		headers    map[string]string
+		want       utils.ClientInfo
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "192.0.2.1:1234",
+			want:       utils.ClientInfo{IP: "192.0.2.1", Scheme: "http", Host: "api.example.com"},
+		},
+		{
+			name:       "direct tls client",
+			remoteAddr: "192.0.2.1:1234",
+			tls:        true,
+			want:       utils.ClientInfo{IP: "192.0.2.1", Scheme: "https", Host: "api.example.com"},
+		},
+		{
+			name:       "untrusted peer can't spoof headers",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Forwarded-Proto": "https", "X-Real-IP": "203.0.113.9"},
+			want:       utils.ClientInfo{IP: "192.0.2.1", Scheme: "http", Host: "api.example.com"},
+		},
+		{
+			name:       "x-forwarded from trusted proxy",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "public.example.com"},
+			want:       utils.ClientInfo{IP: "203.0.113.9", Scheme: "https", Host: "public.example.com"},
+		},
+		{
+			name:       "spoofed left entries are skipped",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7, 203.0.113.9, 10.0.0.3"},
+			want:       utils.ClientInfo{IP: "203.0.113.9", Scheme: "http", Host: "api.example.com"},
+		},
+		{
+			name:       "only trusted proxies in the chain",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.4, 10.0.0.3"},
+			want:       utils.ClientInfo{IP: "10.0.0.4", Scheme: "http", Host: "api.example.com"},
+		},
+		{
+			name:       "forwarded header wins over x-forwarded",
+			remoteAddr: "10.0.0.2:1234",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::1]:4711";proto=https;host=public.example.com`,
+				"X-Forwarded-For": "203.0.113.9",
+			},
+			want: utils.ClientInfo{IP: "2001:db8::1", Scheme: "https", Host: "public.example.com"},
+		},
+		{
+			name:       "unknown ends the chain",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"Forwarded": "for=203.0.113.9, for=unknown"},
+			want:       utils.ClientInfo{IP: "10.0.0.2", Scheme: "http", Host: "api.example.com"},
+		},
+		{
+			name:       "invalid proto and host are ignored",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Forwarded-Proto": "javascript", "X-Forwarded-Host": "evil.example/path"},
+			want:       utils.ClientInfo{IP: "203.0.113.9", Scheme: "http", Host: "api.example.com"},
+		},
+		{
+			name:       "x-real-ip",
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.9"},
+			want:       utils.ClientInfo{IP: "203.0.113.9", Scheme: "http", Host: "api.example.com"},
+		},
+		{
+			name:       "trusted unix socket peer",
+			remoteAddr: "@",
+			unix:       true,
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.9"},
+			want:       utils.ClientInfo{IP: "203.0.113.9", Scheme: "http", Host: "api.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tt.unix {
+				r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, &net.UnixAddr{Name: "/run/app.sock", Net: "unix"}))
+			}
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			if got := resolveClientInfo(r, config); got != tt.want {
+				t.Errorf("resolveClientInfo = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
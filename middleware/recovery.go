@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// recoveryResponseWriter tracks whether the response was already started
+type recoveryResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (rrw *recoveryResponseWriter) WriteHeader(code int) {
+	rrw.wroteHeader = true
+	rrw.ResponseWriter.WriteHeader(code)
+}
+
+func (rrw *recoveryResponseWriter) Write(b []byte) (int, error) {
+	rrw.wroteHeader = true
+	return rrw.ResponseWriter.Write(b)
+}
+
+func (rrw *recoveryResponseWriter) Flush() {
+	rrw.wroteHeader = true
+	http.NewResponseController(rrw.ResponseWriter).Flush()
+}
+
+func (rrw *recoveryResponseWriter) Unwrap() http.ResponseWriter {
+	return rrw.ResponseWriter
+}
+
+// RecoveryMiddleware turns handler panics into a 500 problem response,
+// logs the stack and hands the panic to the configured ErrorReporter
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rrw := &recoveryResponseWriter{ResponseWriter: w}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			stack := debug.Stack()
+			if p, ok := recovered.(handlerPanic); ok {
+				recovered, stack = p.value, p.stack
+			}
+			// net/http uses this panic to abort a response on purpose
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			err, ok := recovered.(error)
+			if !ok {
+				err = fmt.Errorf("%v", recovered)
+			}
+			requestID := utils.GetRequestID(r.Context())
+			event := utils.ErrorEvent{
+				Err:       fmt.Errorf("panic: %w", err),
+				Stack:     stack,
+				RequestID: requestID,
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Time:      time.Now(),
+			}
+			slog.ErrorContext(r.Context(), "panic recovered",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"error", err,
+				"stack", string(event.Stack),
+			)
+			utils.GetErrorReporter().Report(r.Context(), event)
+
+			// too late to change the status once the response has started, abort
+			// it so the client doesn't take a truncated body for a complete one
+			if rrw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			utils.WriteProblem(w, r, http.StatusInternalServerError, utils.ErrCodeInternal, "Internal server error")
+		}()
+
+		next.ServeHTTP(rrw, r)
+	})
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+func setFakeReporter(t *testing.T) *utils.FakeReporter {
+	t.Helper()
+	reporter := &utils.FakeReporter{}
+	utils.SetErrorReporter(reporter)
+	t.Cleanup(func() { utils.SetErrorReporter(utils.NopReporter{}) })
+	return reporter
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	reporter := setFakeReporter(t)
+	handler := RequestIDMiddleware(RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/things", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("content type = %q, want application/problem+json", ct)
+	}
+	events := reporter.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d reported events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Err.Error() != "panic: boom" || event.Method != http.MethodPost || event.Path != "/v1/things" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.RequestID == "" || event.RequestID != w.Header().Get("X-Request-ID") {
+		t.Errorf("event request id = %q, response has %q", event.RequestID, w.Header().Get("X-Request-ID"))
+	}
+	if len(event.Stack) == 0 {
+		t.Error("event has no stack")
+	}
+}
+
+func TestRecoveryMiddlewareAbortsStartedResponse(t *testing.T) {
+	reporter := setFakeReporter(t)
+	handler := RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"items":[`))
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+			}
+		}()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if w.Body.String() != `{"items":[` {
+		t.Errorf("body = %q, want only the partial write", w.Body)
+	}
+	if len(reporter.Events()) != 1 {
+		t.Errorf("got %d reported events, want 1", len(reporter.Events()))
+	}
+}
+
+func TestRecoveryMiddlewareErrAbortHandler(t *testing.T) {
+	reporter := setFakeReporter(t)
+	handler := RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+		if len(reporter.Events()) != 0 {
+			t.Error("intentional abort was reported")
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// RequestIDMiddleware reuses a valid incoming X-Request-ID or generates a ULID,
+// stores it in the request context and echoes it in the response header
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(utils.RequestIDHeader)
+		if !utils.ValidRequestID(requestID) {
+			requestID = utils.NewULID()
+		}
+
+		w.Header().Set(utils.RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), utils.RequestIDKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// routeKey holds the *routeInfo filled while the request walks the nested routers
+type routeKey struct{}
+
+// routeInfo is written by Mount and read by Metrics and Tracing, which may
+// run in another goroutine than the handler, e.g. behind Timeout
+type routeInfo struct {
+	mu     sync.Mutex
+	prefix string
+	route  string
+}
+
+func contextWithRoute(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeKey{}, &routeInfo{})
+}
+
+// GetRoute returns the matched route pattern including mount prefixes,
+// e.g. "/api/v1/me/api-keys/{id}", or "" when no route matched
+func GetRoute(r *http.Request) string {
+	if info, ok := r.Context().Value(routeKey{}).(*routeInfo); ok {
+		info.mu.Lock()
+		defer info.mu.Unlock()
+		return info.route
+	}
+	return ""
+}
+
+// Mount serves mux under prefix like http.StripPrefix and records the matched
+// route pattern, so metrics and logs use "/users/{id}" instead of raw paths.
+// Requests matching no route get problem responses instead of the plain text
+// 404 and 405 of the mux
+func Mount(prefix string, mux *http.ServeMux) http.Handler {
+	return http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			writeUnmatched(w, r, mux)
+			return
+		}
+		if info, ok := r.Context().Value(routeKey{}).(*routeInfo); ok {
+			info.mu.Lock()
+			info.prefix += prefix
+			// drop the method and host parts of "GET example.com/path"
+			if _, path, found := strings.Cut(pattern, " "); found {
+				pattern = path
+			}
+			if i := strings.Index(pattern, "/"); i > 0 {
+				pattern = pattern[i:]
+			}
+			info.route = info.prefix + pattern
+			info.mu.Unlock()
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+// unmatchedWriter keeps the status and headers the mux sets for a request
+// without route and drops its plain text body
+type unmatchedWriter struct {
+	header http.Header
+	code   int
+}
+
+func (uw *unmatchedWriter) Header() http.Header         { return uw.header }
+func (uw *unmatchedWriter) WriteHeader(code int)        { uw.code = code }
+func (uw *unmatchedWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+// writeUnmatched lets the mux decide between 404 and 405, which lists the
+// allowed methods in the Allow header, and writes it as a problem response
+func writeUnmatched(w http.ResponseWriter, r *http.Request, mux *http.ServeMux) {
+	uw := &unmatchedWriter{header: make(http.Header), code: http.StatusNotFound}
+	mux.ServeHTTP(uw, r)
+
+	if uw.code == http.StatusMethodNotAllowed {
+		w.Header().Set("Allow", uw.header.Get("Allow"))
+		utils.WriteProblem(w, r, http.StatusMethodNotAllowed, utils.ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	utils.WriteProblem(w, r, http.StatusNotFound, utils.ErrCodeNotFound, "Not found")
+}
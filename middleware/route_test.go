@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetRouteBehindTimeout(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mounted := Mount("/api", mux)
+
+	// the handler goroutine records the route after Timeout gave up on it
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(finished)
+		<-release
+		mounted.ServeHTTP(w, r)
+	})
+	handler := Timeout(10 * time.Millisecond)(slow)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/users/7", nil)
+	r = r.WithContext(contextWithRoute(r.Context()))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+
+	close(release)
+	for range 100 {
+		GetRoute(r) // runs concurrently with Mount under the race detector
+	}
+	<-finished
+	if got := GetRoute(r); got != "/api/users/{id}" {
+		t.Fatalf("GetRoute = %q, want /api/users/{id}", got)
+	}
+}
+
+func TestMountUnmatchedProblems(t *testing.T) {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	api.HandleFunc("DELETE /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	base := http.NewServeMux()
+	base.Handle("/api/", Mount("/api", api))
+	handler := Mount("", base)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		want      int
+		wantAllow string
+	}{
+		{"matched", http.MethodGet, "/api/users/7", http.StatusOK, ""},
+		{"unknown prefix", http.MethodGet, "/nope", http.StatusNotFound, ""},
+		{"unknown route under a mount", http.MethodGet, "/api/nope", http.StatusNotFound, ""},
+		{"method not allowed", http.MethodPost, "/api/users/7", http.StatusMethodNotAllowed, "DELETE, GET, HEAD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("content type = %q, want application/problem+json", ct)
+			}
+			if allow := w.Header().Get("Allow"); allow != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", allow, tt.wantAllow)
+			}
+		})
+	}
+}
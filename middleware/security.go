@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// SecurityHeadersMiddleware sets HSTS and the default security headers of
+// utils.SecurityHeadersConfig on every response, route groups replace them
+// with their own policy using SecurityHeaders
+func SecurityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setHSTS(w, r)
+		setSecurityHeaders(w.Header(), utils.GetSecurityHeadersConfig().Default)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SecurityHeaders replaces the headers set by SecurityHeadersMiddleware with
+// the policy of a route group, handlers can still override single headers
+func SecurityHeaders(policy utils.SecurityHeaders) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			setSecurityHeaders(w.Header(), policy)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setSecurityHeaders(header http.Header, policy utils.SecurityHeaders) {
+	for _, h := range [...]struct{ name, value string }{
+		{"X-Content-Type-Options", policy.ContentTypeOptions},
+		{"X-Frame-Options", policy.FrameOptions},
+		{"Referrer-Policy", policy.ReferrerPolicy},
+		{"Content-Security-Policy", policy.ContentSecurityPolicy},
+		{"Permissions-Policy", policy.PermissionsPolicy},
+		{"Cross-Origin-Opener-Policy", policy.CrossOriginOpenerPolicy},
+		{"Cross-Origin-Resource-Policy", policy.CrossOriginResourcePolicy},
+		{"Cross-Origin-Embedder-Policy", policy.CrossOriginEmbedderPolicy},
+	} {
+		if h.value == "" {
+			header.Del(h.name)
+		} else {
+			header.Set(h.name, h.value)
+		}
+	}
+}
+
+// setHSTS sets Strict-Transport-Security on https responses, also when a
+// trusted proxy terminates TLS. Browsers ignore the header over plain HTTP
+func setHSTS(w http.ResponseWriter, r *http.Request) {
+	config := utils.GetTLSConfig()
+	https := r.TLS != nil
+	if info, ok := GetClientInfoFromContext(r); ok {
+		https = info.Scheme == "https"
+	}
+	if !https || config.HSTSMaxAge <= 0 {
+		return
+	}
+	value := "max-age=" + strconv.FormatInt(int64(config.HSTSMaxAge.Seconds()), 10)
+	if config.HSTSIncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if config.HSTSPreload {
+		value += "; preload"
+	}
+	w.Header().Set("Strict-Transport-Security", value)
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	panic(errors.New("boom"))
+}
+
+func TestTimeoutPanicKeepsValueAndStack(t *testing.T) {
+	reporter := setFakeReporter(t)
+
+	handler := RecoveryMiddleware(Timeout(time.Second)(http.HandlerFunc(panickingHandler)))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	events := reporter.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d reported events, want 1", len(events))
+	}
+	if events[0].Err.Error() != "panic: boom" {
+		t.Errorf("err = %q, want the original panic value", events[0].Err)
+	}
+	if !strings.Contains(string(events[0].Stack), "panickingHandler") {
+		t.Errorf("stack doesn't include the handler goroutine:\n%s", events[0].Stack)
+	}
+}
+
+func TestTimeoutRepanicsErrAbortHandler(t *testing.T) {
+	handler := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
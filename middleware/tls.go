@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// ClientCertMiddleware puts the identity of a verified client certificate
+// into the request context, unverified certificates are ignored
+func ClientCertMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity := utils.NewClientIdentity(r.TLS.VerifiedChains[0][0])
+		ctx := context.WithValue(r.Context(), utils.ClientIdentityKey, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func GetClientIdentityFromContext(r *http.Request) (*utils.ClientIdentity, bool) {
+	identity, ok := r.Context().Value(utils.ClientIdentityKey).(*utils.ClientIdentity)
+	return identity, ok
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// TracingMiddleware continues the trace from an incoming traceparent header
+// and wraps the request in a server span named after the matched route
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, r.Method,
+			tracing.WithSpanKind(tracing.SpanKindServer),
+			tracing.WithAttributes(
+				tracing.String("http.request.method", r.Method),
+				tracing.String("url.path", r.URL.Path),
+				tracing.String("client.address", GetClientIP(r)),
+				tracing.String("user_agent.original", r.UserAgent()),
+				tracing.String("request.id", utils.GetRequestID(r.Context())),
+			),
+		)
+		defer span.End()
+
+		crw := &customResponseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(crw, r)
+
+		if route := GetRoute(r); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(tracing.String("http.route", route))
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", crw.statusCode))
+		if crw.statusCode >= 500 {
+			span.SetStatus(tracing.StatusError, strconv.Itoa(crw.statusCode))
+		}
+	})
+}
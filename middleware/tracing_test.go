@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
+)
+
+// emptyDriver is a database/sql driver whose queries return no rows, so db
+// functions run their spans without a database
+type emptyDriver struct{}
+
+func (emptyDriver) Open(string) (driver.Conn, error) { return emptyConn{}, nil }
+
+type emptyConn struct{}
+
+func (emptyConn) Prepare(string) (driver.Stmt, error) { return emptyStmt{}, nil }
+func (emptyConn) Close() error                        { return nil }
+func (emptyConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type emptyStmt struct{}
+
+func (emptyStmt) Close() error                               { return nil }
+func (emptyStmt) NumInput() int                              { return -1 }
+func (emptyStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (emptyStmt) Query([]driver.Value) (driver.Rows, error)  { return emptyRows{}, nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"id"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("empty", emptyDriver{})
+}
+
+func TestTracingPropagation(t *testing.T) {
+	exporter := &tracing.InMemoryExporter{}
+	provider := tracing.Setup(tracing.Config{Exporter: exporter, SampleRatio: 1, BatchDelay: time.Hour})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		tracing.Setup(tracing.Config{})
+	})
+
+	conn, err := sql.Open("empty", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousDB := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previousDB })
+
+	var downstreamTraceparent string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstreamTraceparent = r.Header.Get("traceparent")
+	}))
+	defer downstream.Close()
+	client := &http.Client{Transport: &tracing.Transport{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, err := db.GetUserByID(r.Context(), 7)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetUserByID error = %v, want sql.ErrNoRows", err)
+		}
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, downstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	})
+	handler := MetricsMiddleware(TracingMiddleware(Mount("/api", mux)))
+
+	const remoteParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	r := httptest.NewRequest(http.MethodGet, "/api/users/7", nil)
+	r.Header.Set("traceparent", remoteParent)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := provider.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[tracing.SpanKind][]tracing.SpanData{}
+	for _, span := range exporter.Spans() {
+		spans[span.Kind] = append(spans[span.Kind], span)
+	}
+	if len(spans[tracing.SpanKindServer]) != 1 || len(spans[tracing.SpanKindClient]) != 2 {
+		t.Fatalf("exported %+v, want one server and two client spans", exporter.Spans())
+	}
+
+	server := spans[tracing.SpanKindServer][0]
+	if server.Name != "GET /api/users/{id}" {
+		t.Errorf("server span name = %q, want the route", server.Name)
+	}
+	if server.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("server span %s/%s doesn't continue the remote parent", server.SpanContext.TraceID, server.ParentSpanID)
+	}
+
+	names := map[string]tracing.SpanData{}
+	for _, span := range spans[tracing.SpanKindClient] {
+		names[span.Name] = span
+		if span.SpanContext.TraceID != server.SpanContext.TraceID || span.ParentSpanID != server.SpanContext.SpanID {
+			t.Errorf("span %q is not a child of the server span", span.Name)
+		}
+	}
+	if _, ok := names["db.GetUserByID"]; !ok {
+		t.Errorf("no db span in %v", names)
+	}
+	outbound, ok := names["HTTP GET"]
+	if !ok {
+		t.Fatalf("no outbound http span in %v", names)
+	}
+	if downstreamTraceparent != outbound.SpanContext.Traceparent() {
+		t.Errorf("downstream traceparent = %q, want the outbound span %q", downstreamTraceparent, outbound.SpanContext.Traceparent())
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// httpsRedirectHandler sends plain HTTP clients to the same URL over HTTPS,
+// httpsPort is left out of the URL when it is the default 443
+func httpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			utils.WriteProblem(w, r, http.StatusBadRequest, utils.ErrCodeBadRequest, "Missing host")
+			return
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		// 308 keeps the method and body of non GET requests
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, target, status)
+	})
+}
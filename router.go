@@ -3,49 +3,171 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/olksndrdevhub/go-api-starter-kit/handlers"
+	"github.com/olksndrdevhub/go-api-starter-kit/health"
+	"github.com/olksndrdevhub/go-api-starter-kit/metrics"
 	"github.com/olksndrdevhub/go-api-starter-kit/middleware"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
 )
 
-func SetupRouters() http.Handler {
+// Route is a registered route, listed by the routes command
+type Route struct {
+	Method string // empty when the route matches every method
+	Path   string
+}
+
+// router is a ServeMux that records its routes with the full mount prefix
+type router struct {
+	*http.ServeMux
+	prefix string
+	routes *[]Route
+}
+
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = "", pattern
+	}
+	*rt.routes = append(*rt.routes, Route{Method: method, Path: rt.prefix + path})
+	rt.ServeMux.Handle(pattern, handler)
+}
+
+func (rt *router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// group returns a router for routes mounted under prefix with mount
+func (rt *router) group(prefix string) *router {
+	return &router{ServeMux: http.NewServeMux(), prefix: rt.prefix + prefix, routes: rt.routes}
+}
+
+// mount serves sub under its prefix, mounts are not recorded as routes
+func (rt *router) mount(sub *router, middlewares ...middleware.Middleware) {
+	prefix := strings.TrimPrefix(sub.prefix, rt.prefix)
+	rt.ServeMux.Handle(prefix+"/", middleware.CreateStuck(middlewares...)(middleware.Mount(prefix, sub.ServeMux)))
+}
+
+// SetupRouters builds the app handler and returns its route table,
+// serveMetrics exposes /status/metrics when metrics are not served on a separate listener
+func SetupRouters(serveMetrics bool) (http.Handler, []Route) {
 
-	baseRouter := http.NewServeMux()
+	routes := []Route{}
+	baseRouter := &router{ServeMux: http.NewServeMux(), routes: &routes}
 
 	// health check router
-	statusRouter := http.NewServeMux()
+	statusRouter := baseRouter.group("/status")
 	statusRouter.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprintf(w, `{"status": "ok"}`)
 	})
+	statusRouter.HandleFunc("GET /live", health.LiveHandler)
+	statusRouter.HandleFunc("GET /ready", health.ReadyHandler)
+	if serveMetrics {
+		statusRouter.Handle("GET /metrics", metrics.Handler())
+	}
+
+	// per route rate limits, login methods share one counter per client ip
+	rateLimits := utils.GetRateLimitConfig()
+	loginLimit := middleware.RateLimit(rateLimits.Login, middleware.KeyByIP)
+	authLimit := middleware.RateLimit(rateLimits.Auth, middleware.KeyByIP)
+
+	// auth middlewares for unsafe API router
+	jwtStuck := middleware.CreateStuck(
+		middleware.JWTMiddleware,
+		middleware.RateLimit(rateLimits.API, middleware.KeyByAPIKey),
+		middleware.CSRFMiddleware,
+		middleware.ScopeMiddleware,
+		middleware.RequireJSON,
+	)
+
+	// auth middlewares for endpoints that require a user session (no API keys)
+	sessionStuck := middleware.CreateStuck(
+		middleware.JWTMiddleware,
+		middleware.CSRFMiddleware,
+		middleware.RequireSessionAuth,
+	)
+
+	// api versioning
+	apiV1Router := baseRouter.group("/api")
 
 	// safe apiRouter (no auth)
-	apiRouter := http.NewServeMux()
-	apiRouter.HandleFunc("POST /register", handlers.Register)
-	apiRouter.HandleFunc("POST /login", handlers.Login)
+	apiRouter := apiV1Router.group("/v1/auth")
+	apiRouter.Handle("POST /register", middleware.CreateStuck(middleware.RateLimit(rateLimits.Register, middleware.KeyByIP), middleware.RequireJSON)(http.HandlerFunc(handlers.Register)))
+	apiRouter.Handle("POST /login", middleware.CreateStuck(loginLimit, middleware.RequireJSON, middleware.LoginMetrics("password"))(http.HandlerFunc(handlers.Login)))
+	apiRouter.Handle("POST /logout", middleware.SessionCookieCSRFMiddleware(http.HandlerFunc(handlers.Logout)))
+	apiRouter.Handle("POST /magic-link", middleware.CreateStuck(loginLimit, middleware.RequireJSON)(http.HandlerFunc(handlers.RequestMagicLink)))
+	apiRouter.HandleFunc("GET /magic-link/verify", handlers.MagicLinkPage)
+	apiRouter.Handle("POST /magic-link/verify", middleware.CreateStuck(loginLimit, middleware.RequireContentType("application/json", "application/x-www-form-urlencoded"), middleware.LoginMetrics("magic_link"))(http.HandlerFunc(handlers.ConsumeMagicLink)))
+	apiRouter.HandleFunc("GET /oidc/{provider}/login", handlers.OIDCLogin)
+	apiRouter.Handle("GET /oidc/{provider}/callback", middleware.LoginMetrics("oidc")(http.HandlerFunc(handlers.OIDCCallback)))
+	apiRouter.Handle("POST /webauthn/register/begin", sessionStuck(http.HandlerFunc(handlers.WebAuthnRegisterBegin)))
+	apiRouter.Handle("POST /webauthn/register/finish", sessionStuck(middleware.RequireJSON(http.HandlerFunc(handlers.WebAuthnRegisterFinish))))
+	apiRouter.Handle("POST /webauthn/login/begin", middleware.CreateStuck(loginLimit, middleware.RequireJSON)(http.HandlerFunc(handlers.WebAuthnLoginBegin)))
+	apiRouter.Handle("POST /webauthn/login/finish", middleware.CreateStuck(loginLimit, middleware.RequireJSON, middleware.LoginMetrics("webauthn"))(http.HandlerFunc(handlers.WebAuthnLoginFinish)))
 
 	// unsafe API router (jwt auth)
-	apiJwtRouter := http.NewServeMux()
+	apiJwtRouter := apiV1Router.group("/v1")
 	apiJwtRouter.HandleFunc("/me", handlers.Profile)
-	apiJwtRouter.HandleFunc("POST /me/change-password", handlers.ChangePassword)
+	apiJwtRouter.Handle("POST /me/change-password", middleware.RequireSessionAuth(http.HandlerFunc(handlers.ChangePassword)))
+	apiJwtRouter.Handle("GET /me/api-keys", middleware.RequireSessionAuth(http.HandlerFunc(handlers.ListAPIKeys)))
+	apiJwtRouter.Handle("POST /me/api-keys", middleware.RequireSessionAuth(http.HandlerFunc(handlers.CreateAPIKey)))
+	apiJwtRouter.Handle("DELETE /me/api-keys/{id}", middleware.RequireSessionAuth(http.HandlerFunc(handlers.DeleteAPIKey)))
+	apiJwtRouter.Handle("GET /oauth/clients", middleware.RequireSessionAuth(http.HandlerFunc(handlers.ListOAuthClients)))
+	apiJwtRouter.Handle("POST /oauth/clients", middleware.RequireSessionAuth(http.HandlerFunc(handlers.CreateOAuthClient)))
+	apiJwtRouter.Handle("DELETE /oauth/clients/{client_id}", middleware.RequireSessionAuth(http.HandlerFunc(handlers.DeleteOAuthClient)))
 
-	// api versioning
-	apiV1Router := http.NewServeMux()
-	apiV1Router.Handle("/v1/auth/", http.StripPrefix("/v1/auth", apiRouter))
-	apiV1Router.Handle("/v1/", middleware.JWTMiddleware(http.StripPrefix("/v1", apiJwtRouter)))
+	// handler timeout for API routes, status endpoints are not limited
+	timeout := middleware.Timeout(utils.GetServerConfig().HandlerTimeout)
+
+	// unauthenticated endpoints only take small payloads
+	apiV1Router.mount(apiRouter, authLimit, middleware.BodyLimit(64<<10), timeout)
+	apiV1Router.mount(apiJwtRouter, timeout, jwtStuck)
+
+	// oauth2 authorization server, authorize requires the browser session of
+	// cookie mode, users without one are sent to the login page first
+	oauthRouter := baseRouter.group("/oauth")
+	oauthRouter.Handle("GET /authorize", handlers.OAuthLoginRequired(sessionStuck(http.HandlerFunc(handlers.OAuthAuthorize))))
+	oauthRouter.Handle("POST /authorize", sessionStuck(http.HandlerFunc(handlers.OAuthAuthorizeDecision)))
+	oauthRouter.HandleFunc("POST /token", handlers.OAuthToken)
+	oauthRouter.HandleFunc("GET /userinfo", handlers.OAuthUserInfo)
+	oauthRouter.HandleFunc("POST /userinfo", handlers.OAuthUserInfo)
+	oauthRouter.HandleFunc("POST /introspect", handlers.OAuthIntrospect)
+	oauthRouter.HandleFunc("POST /revoke", handlers.OAuthRevoke)
+	oauthRouter.HandleFunc("GET /jwks", handlers.OAuthJWKS)
 
 	// admin router (TODO:)
-	adminRouter := http.NewServeMux()
+	adminRouter := baseRouter.group("/admin")
 	adminRouter.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
 
-	baseRouter.Handle("/api/", http.StripPrefix("/api", apiV1Router))
-	baseRouter.Handle("/admin/", http.StripPrefix("/admin", adminRouter))
-	baseRouter.Handle("/status/", http.StripPrefix("/status", statusRouter))
+	// stricter security headers for the API, no form-action for the oauth
+	// consent redirect, relaxed ones for the admin UI
+	securityHeaders := utils.GetSecurityHeadersConfig()
+
+	// Mount records route patterns for metrics
+	baseRouter.mount(apiV1Router, middleware.SecurityHeaders(securityHeaders.API))
+	baseRouter.mount(adminRouter, middleware.SecurityHeaders(securityHeaders.Admin))
+	baseRouter.mount(statusRouter)
+	baseRouter.mount(oauthRouter, middleware.SecurityHeaders(securityHeaders.OAuth), authLimit, timeout)
+	baseRouter.HandleFunc("GET /.well-known/openid-configuration", handlers.OpenIDConfiguration)
 
 	middlewareStuck := middleware.CreateStuck(
+		middleware.RequestIDMiddleware,
+		middleware.ClientIPMiddleware,
+		middleware.SecurityHeadersMiddleware,
+		middleware.MetricsMiddleware,
+		middleware.TracingMiddleware,
 		middleware.LogsMiddleware,
+		// inside the logs middleware, so logged sizes are the compressed ones
+		middleware.CompressMiddleware,
+		middleware.RecoveryMiddleware,
+		// preflights are answered before auth middlewares can reject them
+		middleware.CORSMiddleware,
+		middleware.BodyLimitMiddleware,
+		middleware.ClientCertMiddleware,
 	)
 
-	return middlewareStuck(baseRouter)
+	return middlewareStuck(middleware.Mount("", baseRouter.ServeMux)), routes
 
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/config"
+	"github.com/olksndrdevhub/go-api-starter-kit/db"
+	"github.com/olksndrdevhub/go-api-starter-kit/health"
+	"github.com/olksndrdevhub/go-api-starter-kit/metrics"
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// serve runs the API server until SIGINT/SIGTERM, then drains connections
+func serve(ctx context.Context, cfg *config.Config) error {
+	if cfg.DB.AutoMigrate {
+		if err := db.Migrate(ctx); err != nil {
+			return fmt.Errorf("migrate database: %w", err)
+		}
+	}
+	metrics.RegisterDBStats(db.DB.Stats)
+	registerHealthChecks()
+
+	// registered before the listeners start so an early signal still drains
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+
+	serverConfig := utils.GetServerConfig()
+	tlsConfig := utils.GetTLSConfig()
+
+	// metrics on a separate listener keep them off the public port
+	metricsAddr := cfg.Server.MetricsAddr
+	serverErr := make(chan error, 3)
+	var metricsServer *http.Server
+	if metricsAddr != "" {
+		metricsServer = newServer(metrics.Handler(), serverConfig)
+		metricsServer.Addr = metricsAddr
+		go func() {
+			slog.Info("metrics server is starting", "addr", metricsAddr)
+			serverErr <- metricsServer.ListenAndServe()
+		}()
+	}
+
+	handler, _ := SetupRouters(metricsAddr == "")
+
+	// listen_addr also takes unix:/path/to/socket or fd:N
+	addr := cfg.Server.ListenAddr
+	if addr == "" {
+		addr = ":" + cfg.Server.Port
+	}
+	listener, err := listen(addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	server := newServer(handler, serverConfig)
+
+	var redirectServer *http.Server
+	if tlsConfig.CertFile != "" {
+		server.TLSConfig, err = utils.NewServerTLSConfig(tlsConfig)
+		if err != nil {
+			return fmt.Errorf("configure tls: %w", err)
+		}
+
+		// plain HTTP listener that only redirects to HTTPS, e.g. HTTP_REDIRECT_ADDR=:80
+		if redirectAddr := cfg.TLS.RedirectAddr; redirectAddr != "" {
+			httpsPort := cfg.TLS.PublicPort
+			if httpsPort == "" {
+				_, httpsPort, _ = net.SplitHostPort(listener.Addr().String())
+			}
+			redirectServer = newServer(httpsRedirectHandler(httpsPort), serverConfig)
+			redirectServer.Addr = redirectAddr
+			go func() {
+				slog.Info("https redirect server is starting", "addr", redirectAddr)
+				serverErr <- redirectServer.ListenAndServe()
+			}()
+		}
+	}
+
+	go func() {
+		slog.Info("server is starting", "addr", listener.Addr().String(), "tls", server.TLSConfig != nil)
+		if server.TLSConfig != nil {
+			// certificates come from TLSConfig.GetCertificate
+			serverErr <- server.ServeTLS(listener, "", "")
+			return
+		}
+		serverErr <- server.Serve(listener)
+	}()
+
+	// wait for SIGINT/SIGTERM or a listener failing
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown signal received")
+	case serveErr = <-serverErr:
+		serveErr = fmt.Errorf("server stopped: %w", serveErr)
+	}
+	// a second signal kills the process right away
+	stop()
+
+	shutdownDelay := cfg.Server.ShutdownDelay
+	drainTimeout := cfg.Server.ShutdownTimeout
+
+	steps := []shutdownStep{
+		{
+			// fail readiness first and give the load balancer time to notice,
+			// so no new requests are routed here while draining
+			name:    "readiness",
+			timeout: shutdownDelay + time.Second,
+			stop: func(ctx context.Context) error {
+				health.SetShuttingDown()
+				return sleepContext(ctx, shutdownDelay)
+			},
+		},
+		{name: "http server", timeout: drainTimeout, stop: drainServer(server)},
+		// e.g. magic link emails queued by requests that already returned
+		{name: "background tasks", timeout: 30 * time.Second, stop: utils.WaitForBackground},
+	}
+	if redirectServer != nil {
+		steps = append(steps, shutdownStep{name: "https redirect server", timeout: 5 * time.Second, stop: drainServer(redirectServer)})
+	}
+	if metricsServer != nil {
+		// scraped until the end so the drain shows up in metrics
+		steps = append(steps, shutdownStep{name: "metrics server", timeout: 5 * time.Second, stop: drainServer(metricsServer)})
+	}
+	// tracing and the database are closed by main after the command returns
+	runShutdown(steps)
+
+	return serveErr
+}
+
+// newServer applies the configured timeouts and header limit
+func newServer(handler http.Handler, config utils.ServerConfig) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// registerHealthChecks sets up the dependency checks behind /status/ready
+func registerHealthChecks() {
+	health.Register(health.Check{
+		Name:     "database",
+		Check:    db.Ping,
+		Critical: true,
+	})
+	health.Register(health.Check{
+		Name: "migrations",
+		Check: func(ctx context.Context) error {
+			pending, err := db.PendingMigrations(ctx)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("%d pending migrations", len(pending))
+			}
+			return nil
+		},
+		Critical: true,
+	})
+
+	// the log mailer used in development has nothing to check
+	if mailer, ok := utils.GetMailer().(utils.MailerPinger); ok {
+		health.Register(health.Check{
+			Name:     "mailer",
+			Check:    mailer.Ping,
+			Timeout:  3 * time.Second,
+			Critical: false,
+		})
+	}
+}
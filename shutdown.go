@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// shutdownStep stops one component, steps run in order so each one can
+// still use the components stopped after it (handlers need the DB...)
+type shutdownStep struct {
+	name    string
+	timeout time.Duration
+	stop    func(ctx context.Context) error
+}
+
+// runShutdown runs the steps in order, a step that fails or times out is
+// logged and the remaining steps still run
+func runShutdown(steps []shutdownStep) {
+	for _, step := range steps {
+		ctx, cancel := context.WithTimeout(context.Background(), step.timeout)
+		startTime := time.Now()
+		err := step.stop(ctx)
+		cancel()
+
+		if err != nil {
+			slog.Error("shutdown step failed", "step", step.name, "duration", time.Since(startTime), "error", err)
+			continue
+		}
+		slog.Info("shutdown step done", "step", step.name, "duration", time.Since(startTime))
+	}
+}
+
+// drainServer stops accepting connections and waits for in flight requests,
+// connections still busy when ctx expires are closed
+func drainServer(server *http.Server) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		err := server.Shutdown(ctx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			server.Close()
+		}
+		return err
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,473 @@
+// Package tracing records spans compatible with OpenTelemetry, propagates
+// W3C trace context and exports spans over OTLP/HTTP.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+func (t TraceID) IsValid() bool  { return t != TraceID{} }
+func (s SpanID) IsValid() bool   { return s != SpanID{} }
+
+// SpanContext identifies a span across process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool // extracted from an incoming request
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind follows the OTLP enum values
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode follows the OTLP enum values
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute is a span attribute, values are string, bool, int64 or float64
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute          { return Attribute{key, value} }
+func Int64(key string, value int64) Attribute     { return Attribute{key, value} }
+func Int(key string, value int) Attribute         { return Attribute{key, int64(value)} }
+func Bool(key string, value bool) Attribute       { return Attribute{key, value} }
+func Float64(key string, value float64) Attribute { return Attribute{key, value} }
+
+// Span is a timed operation, spans that are not sampled only carry context
+type Span struct {
+	mu           sync.Mutex
+	name         string
+	kind         SpanKind
+	spanContext  SpanContext
+	parentSpanID SpanID
+	start        time.Time
+	end          time.Time
+	attributes   []Attribute
+	status       StatusCode
+	statusMsg    string
+	ended        bool
+	provider     *Provider
+}
+
+// SpanData is the read only snapshot handed to exporters
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.spanContext
+}
+
+func (s *Span) IsRecording() bool {
+	return s != nil && s.spanContext.Sampled && s.provider != nil
+}
+
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attributes...)
+}
+
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+	s.statusMsg = message
+}
+
+// RecordError marks the span failed, nil errors are ignored
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetAttributes(String("exception.message", err.Error()))
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finishes the span and queues it for export, later calls are no-ops
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	data := SpanData{
+		Name:          s.name,
+		Kind:          s.kind,
+		SpanContext:   s.spanContext,
+		ParentSpanID:  s.parentSpanID,
+		Start:         s.start,
+		End:           s.end,
+		Attributes:    append([]Attribute(nil), s.attributes...),
+		Status:        s.status,
+		StatusMessage: s.statusMsg,
+	}
+	s.mu.Unlock()
+
+	s.provider.enqueue(data)
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// SpanFromContext returns the active span, nil when there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the active span context or the remote parent
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.spanContext
+	}
+	remote, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return remote
+}
+
+// ContextWithRemoteSpanContext sets the parent for spans started from ctx
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+type startConfig struct {
+	kind       SpanKind
+	attributes []Attribute
+}
+
+type StartOption func(*startConfig)
+
+func WithSpanKind(kind SpanKind) StartOption {
+	return func(c *startConfig) { c.kind = kind }
+}
+
+func WithAttributes(attributes ...Attribute) StartOption {
+	return func(c *startConfig) { c.attributes = append(c.attributes, attributes...) }
+}
+
+// Start begins a span as child of the span in ctx using the global provider,
+// the returned span must be ended by the caller
+func Start(ctx context.Context, name string, options ...StartOption) (context.Context, *Span) {
+	return globalProvider().Start(ctx, name, options...)
+}
+
+// Config configures the global tracer provider
+type Config struct {
+	ServiceName string
+	Exporter    Exporter // nil disables recording, trace context is still propagated
+	SampleRatio float64  // share of new traces recorded, incoming sampled flags are respected
+	BatchSize   int
+	BatchDelay  time.Duration
+}
+
+// Provider creates spans and batches them to the exporter
+type Provider struct {
+	config Config
+	queue  chan SpanData
+	flush  chan chan struct{}
+	done   chan struct{}
+	mu     sync.RWMutex // guards closed against sends on the closed queue
+	closed bool
+}
+
+var (
+	provider   *Provider
+	providerMu sync.RWMutex
+)
+
+func globalProvider() *Provider {
+	providerMu.RLock()
+	defer providerMu.RUnlock()
+	return provider
+}
+
+// Setup installs the global provider, call Shutdown to flush pending spans
+func Setup(config Config) *Provider {
+	if config.ServiceName == "" {
+		config.ServiceName = "go-api-starter-kit"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.BatchDelay <= 0 {
+		config.BatchDelay = 5 * time.Second
+	}
+
+	p := &Provider{
+		config: config,
+		queue:  make(chan SpanData, config.BatchSize*4),
+		flush:  make(chan chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if config.Exporter != nil {
+		go p.run()
+	}
+
+	providerMu.Lock()
+	provider = p
+	providerMu.Unlock()
+	return p
+}
+
+func (p *Provider) Start(ctx context.Context, name string, options ...StartOption) (context.Context, *Span) {
+	config := startConfig{kind: SpanKindInternal}
+	for _, option := range options {
+		option(&config)
+	}
+
+	parent := SpanContextFromContext(ctx)
+	span := &Span{
+		name:       name,
+		kind:       config.kind,
+		start:      time.Now(),
+		attributes: config.attributes,
+	}
+
+	if parent.IsValid() {
+		span.spanContext.TraceID = parent.TraceID
+		span.spanContext.Sampled = parent.Sampled
+		span.parentSpanID = parent.SpanID
+	} else {
+		rand.Read(span.spanContext.TraceID[:])
+		span.spanContext.Sampled = p != nil && sampled(span.spanContext.TraceID, p.config.SampleRatio)
+	}
+	rand.Read(span.spanContext.SpanID[:])
+
+	if p != nil && p.config.Exporter != nil {
+		span.provider = p
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// sampled decides from the trace id so every service makes the same choice
+func sampled(traceID TraceID, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	value := binary.BigEndian.Uint64(traceID[8:]) >> 1
+	return value < uint64(ratio*(1<<63))
+}
+
+func (p *Provider) enqueue(data SpanData) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- data:
+	default:
+		// drop spans rather than block requests when the exporter falls behind
+	}
+}
+
+func (p *Provider) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.BatchDelay)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, p.config.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		p.config.Exporter.ExportSpans(ctx, batch)
+		cancel()
+		batch = make([]SpanData, 0, p.config.BatchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-p.queue:
+			if !ok {
+				export()
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= p.config.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-p.flush:
+			// drain what is already queued
+			for drained := false; !drained; {
+				select {
+				case data := <-p.queue:
+					batch = append(batch, data)
+				default:
+					drained = true
+				}
+			}
+			export()
+			close(flushed)
+		}
+	}
+}
+
+// ForceFlush exports queued spans, useful in tests with the in memory exporter
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	if p == nil || p.config.Exporter == nil {
+		return nil
+	}
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown flushes pending spans and stops the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil || p.config.Exporter == nil {
+		return nil
+	}
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.config.Exporter.Shutdown(ctx)
+}
+
+// Shutdown flushes the global provider
+func Shutdown(ctx context.Context) error {
+	return globalProvider().Shutdown(ctx)
+}
+
+const traceparentHeader = "traceparent"
+
+// ParseTraceparent parses a W3C traceparent header: version-traceid-spanid-flags
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, fmt.Errorf("invalid traceparent")
+	}
+	version, err := hex.DecodeString(value[0:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(value) != 55) {
+		return sc, fmt.Errorf("invalid traceparent version")
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(value[3:35])); err != nil {
+		return sc, fmt.Errorf("invalid trace id")
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(value[36:52])); err != nil {
+		return sc, fmt.Errorf("invalid span id")
+	}
+	flags, err := hex.DecodeString(value[53:55])
+	if err != nil {
+		return sc, fmt.Errorf("invalid trace flags")
+	}
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent ids")
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	return sc, nil
+}
+
+// Traceparent formats the span context as a W3C traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// Extract returns ctx with the remote parent from the traceparent header, if valid
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(traceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject writes the traceparent of the active span in ctx to header
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(traceparentHeader, sc.Traceparent())
+}
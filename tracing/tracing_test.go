@@ -0,0 +1,244 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// setupInMemory installs a provider recording to memory, new traces are
+// sampled with ratio
+func setupInMemory(t *testing.T, ratio float64) (*Provider, *InMemoryExporter) {
+	t.Helper()
+	exporter := &InMemoryExporter{}
+	p := Setup(Config{Exporter: exporter, SampleRatio: ratio, BatchDelay: time.Hour})
+	t.Cleanup(func() {
+		p.Shutdown(context.Background())
+		Setup(Config{})
+	})
+	return p, exporter
+}
+
+func flush(t *testing.T, p *Provider) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantErr     bool
+		wantSampled bool
+	}{
+		{name: "sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantSampled: true},
+		{name: "not sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{name: "other flags", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09", wantSampled: true},
+		{name: "future version with extra fields", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantSampled: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "version 00 with extra fields", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: true},
+		{name: "version ff", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero span id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+		{name: "not hex", value: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", wantErr: true},
+		{name: "bad separators", value: "00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01", wantErr: true},
+		{name: "bad flags", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTraceparent(%q) = %+v, want error", tt.value, sc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTraceparent(%q) failed: %v", tt.value, err)
+			}
+			if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+				t.Errorf("ids = %s %s", sc.TraceID, sc.SpanID)
+			}
+			if sc.Sampled != tt.wantSampled {
+				t.Errorf("sampled = %v, want %v", sc.Sampled, tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		sc := SpanContext{TraceID: TraceID{1, 2, 3}, SpanID: SpanID{4, 5, 6}, Sampled: sampled}
+		parsed, err := ParseTraceparent(sc.Traceparent())
+		if err != nil || parsed != sc {
+			t.Fatalf("round trip of %+v = %+v, %v", sc, parsed, err)
+		}
+	}
+}
+
+func TestSampled(t *testing.T) {
+	traceID := func(n uint64) TraceID {
+		var id TraceID
+		binary.BigEndian.PutUint64(id[8:], n)
+		return id
+	}
+
+	tests := []struct {
+		name    string
+		traceID TraceID
+		ratio   float64
+		want    bool
+	}{
+		{"ratio 1", traceID(^uint64(0)), 1, true},
+		{"ratio 0", traceID(0), 0, false},
+		{"low id below ratio", traceID(1 << 60), 0.5, true},
+		{"high id above ratio", traceID(^uint64(0)), 0.5, false},
+		{"at the boundary", traceID(1 << 63), 0.5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sampled(tt.traceID, tt.ratio); got != tt.want {
+				t.Errorf("sampled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// random trace ids are sampled close to the ratio
+	p, _ := setupInMemory(t, 0.25)
+	recorded := 0
+	for range 4000 {
+		_, span := p.Start(context.Background(), "op")
+		if span.IsRecording() {
+			recorded++
+		}
+	}
+	if recorded < 800 || recorded > 1200 {
+		t.Errorf("recorded %d of 4000 spans, want about 1000", recorded)
+	}
+}
+
+func TestSamplingFollowsParent(t *testing.T) {
+	tests := []struct {
+		name   string
+		ratio  float64
+		parent SpanContext
+		want   bool
+	}{
+		{"sampled parent, ratio 0", 0, SpanContext{TraceID: TraceID{1}, SpanID: SpanID{1}, Sampled: true}, true},
+		{"unsampled parent, ratio 1", 1, SpanContext{TraceID: TraceID{2}, SpanID: SpanID{2}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, exporter := setupInMemory(t, tt.ratio)
+			_, span := p.Start(ContextWithRemoteSpanContext(context.Background(), tt.parent), "op")
+			span.End()
+
+			flush(t, p)
+			if got := len(exporter.Spans()) == 1; got != tt.want {
+				t.Fatalf("exported = %v, want %v", got, tt.want)
+			}
+			if span.SpanContext().TraceID != tt.parent.TraceID {
+				t.Errorf("trace id = %s, want the parent's %s", span.SpanContext().TraceID, tt.parent.TraceID)
+			}
+		})
+	}
+}
+
+func TestInMemoryExporter(t *testing.T) {
+	p, exporter := setupInMemory(t, 1)
+
+	ctx, parent := p.Start(context.Background(), "parent", WithSpanKind(SpanKindServer))
+	_, child := p.Start(ctx, "child", WithAttributes(String("key", "value")))
+	child.RecordError(errors.New("failed"))
+	child.End()
+	child.End() // ending twice exports once
+	parent.SetName("renamed")
+	parent.End()
+	parent.SetName("after end")
+
+	flush(t, p)
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	childData, parentData := spans[0], spans[1]
+	if parentData.Name != "renamed" || parentData.Kind != SpanKindServer || parentData.ParentSpanID.IsValid() {
+		t.Errorf("unexpected parent %+v", parentData)
+	}
+	if childData.SpanContext.TraceID != parentData.SpanContext.TraceID || childData.ParentSpanID != parentData.SpanContext.SpanID {
+		t.Errorf("child %+v is not a child of %+v", childData.SpanContext, parentData.SpanContext)
+	}
+	if childData.Status != StatusError || childData.StatusMessage != "failed" {
+		t.Errorf("child status = %d %q, want error", childData.Status, childData.StatusMessage)
+	}
+	if len(childData.Attributes) == 0 || childData.Attributes[0] != String("key", "value") {
+		t.Errorf("child attributes = %+v", childData.Attributes)
+	}
+
+	exporter.Reset()
+	if len(exporter.Spans()) != 0 {
+		t.Error("Reset kept spans")
+	}
+
+	// spans ended after shutdown are dropped
+	p.Shutdown(context.Background())
+	_, late := p.Start(context.Background(), "late")
+	late.End()
+	if len(exporter.Spans()) != 0 {
+		t.Error("span ended after shutdown was exported")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	p, exporter := setupInMemory(t, 1)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, parent := p.Start(context.Background(), "parent")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/path?secret=1", nil)
+	resp, err := (&http.Client{Transport: &Transport{}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	if req.Header.Get("traceparent") != "" {
+		t.Error("transport modified the caller's request")
+	}
+
+	flush(t, p)
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	client := spans[0]
+	if client.Kind != SpanKindClient || client.ParentSpanID != parent.SpanContext().SpanID {
+		t.Errorf("client span %+v is not a child of the parent", client)
+	}
+	if traceparent != client.SpanContext.Traceparent() {
+		t.Errorf("downstream traceparent = %q, want the client span %q", traceparent, client.SpanContext.Traceparent())
+	}
+	if client.Status != StatusError {
+		t.Errorf("client status = %d, want error for 502", client.Status)
+	}
+	for _, attribute := range client.Attributes {
+		if attribute.Key == "url.full" && attribute.Value != server.URL+"/path" {
+			t.Errorf("url.full = %v, the query must not be recorded", attribute.Value)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix marks personal API keys so they can be told apart from JWT tokens
+const APIKeyPrefix = "sk_"
+
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
+
+var APIKeyScopes = []string{APIKeyScopeRead, APIKeyScopeWrite}
+
+// GenerateAPIKey creates a new random API key, returns the full key (shown once),
+// its public prefix used for identification and the hash stored in the database
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	idBytes := make([]byte, 4)
+	_, err = rand.Read(idBytes)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix = APIKeyPrefix + hex.EncodeToString(idBytes)
+	key = prefix + "_" + secret
+
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes the API key for storage and lookup
+func HashAPIKey(key string) string {
+	return HashToken(key)
+}
+
+// HashToken hashes random tokens (API keys, OAuth tokens, codes) for storage,
+// tokens are high entropy random values so a fast hash is sufficient
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether the token looks like a personal API key
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// background counts functions started by RunInBackground, idle is closed
+// when the count drops to zero
+var background struct {
+	mu      sync.Mutex
+	running int
+	idle    chan struct{}
+}
+
+// RunInBackground runs fn after the response without waiting for it. The
+// context is detached from the request cancellation but keeps its values for
+// logs and traces, and fn gets at most timeout. WaitForBackground waits for
+// running functions on shutdown
+func RunInBackground(ctx context.Context, timeout time.Duration, fn func(ctx context.Context)) {
+	background.mu.Lock()
+	if background.running == 0 {
+		background.idle = make(chan struct{})
+	}
+	background.running++
+	background.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() {
+			background.mu.Lock()
+			background.running--
+			if background.running == 0 {
+				close(background.idle)
+			}
+			background.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// WaitForBackground waits until functions started by RunInBackground return
+// or ctx is done
+func WaitForBackground(ctx context.Context) error {
+	background.mu.Lock()
+	if background.running == 0 {
+		background.mu.Unlock()
+		return nil
+	}
+	idle := background.idle
+	background.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type backgroundTestKey struct{}
+
+func TestRunInBackground(t *testing.T) {
+	requestCtx, cancelRequest := context.WithCancel(context.WithValue(context.Background(), backgroundTestKey{}, "request"))
+
+	release := make(chan struct{})
+	result := make(chan error, 1)
+	RunInBackground(requestCtx, time.Minute, func(ctx context.Context) {
+		<-release
+		if ctx.Value(backgroundTestKey{}) != "request" {
+			t.Error("context lost the request values")
+		}
+		result <- ctx.Err()
+	})
+
+	// the request finishing must not cancel the work
+	cancelRequest()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := WaitForBackground(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("WaitForBackground = %v while work is running, want deadline exceeded", err)
+	}
+
+	close(release)
+	if err := WaitForBackground(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("background context err = %v after the request was canceled", err)
+	}
+}
+
+func TestRunInBackgroundTimeout(t *testing.T) {
+	RunInBackground(context.Background(), time.Millisecond, func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := WaitForBackground(ctx); err != nil {
+		t.Fatalf("background work outlived its timeout: %v", err)
+	}
+}
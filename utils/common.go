@@ -2,9 +2,13 @@ package utils
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -34,7 +38,7 @@ func ParseTime(timeStr string) (time.Time, error) {
 	}
 
 	if parserErr != nil {
-		log.Printf("Error parsing time: %v", parserErr)
+		slog.Warn("parse time failed", "value", timeStr, "error", parserErr)
 		return time.Time{}, parserErr
 	}
 
@@ -49,3 +53,52 @@ func GetEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// GetEnvBool gets a boolean environment variable or returns a default value
+func GetEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// DecodeJSON strictly decodes the request body into v, unknown fields
+// and trailing data after the JSON value are rejected
+func DecodeJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return NewPayloadTooLargeError(maxBytesErr.Limit)
+		}
+		if field, found := strings.CutPrefix(err.Error(), "json: unknown field "); found {
+			apiErr := NewAPIError(http.StatusBadRequest, ErrCodeInvalidBody, "Request body contains unknown field")
+			apiErr.Errors = []FieldError{{Field: strings.Trim(field, `"`), Code: "unknown_field", Message: "is not allowed"}}
+			return apiErr
+		}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			apiErr := NewAPIError(http.StatusBadRequest, ErrCodeInvalidBody, "Request body contains invalid value type")
+			apiErr.Errors = []FieldError{{Field: typeErr.Field, Code: "type", Message: "must be " + typeErr.Type.String()}}
+			return apiErr
+		}
+		return NewAPIError(http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+	}
+
+	if decoder.More() {
+		return NewAPIError(http.StatusBadRequest, ErrCodeInvalidBody, "Request body must contain a single JSON value")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return NewPayloadTooLargeError(maxBytesErr.Limit)
+		}
+		return NewAPIError(http.StatusBadRequest, ErrCodeInvalidBody, "Request body must contain a single JSON value")
+	}
+
+	return nil
+}
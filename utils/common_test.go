@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type request struct {
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		maxBytes   int64
+		wantStatus int // 0 when the body decodes
+		wantField  string
+	}{
+		{name: "valid", body: `{"email":"user@example.com","age":30}`},
+		{name: "trailing whitespace", body: "{\"email\":\"user@example.com\"}\n  "},
+		{name: "unknown field", body: `{"email":"user@example.com","admin":true}`, wantStatus: http.StatusBadRequest, wantField: "admin"},
+		{name: "wrong type", body: `{"age":"thirty"}`, wantStatus: http.StatusBadRequest, wantField: "age"},
+		{name: "second value", body: `{"email":"a@b.co"}{"email":"c@d.co"}`, wantStatus: http.StatusBadRequest},
+		{name: "trailing garbage", body: `{"email":"a@b.co"} x`, wantStatus: http.StatusBadRequest},
+		{name: "trailing bracket", body: `{"email":"a@b.co"}]`, wantStatus: http.StatusBadRequest},
+		{name: "malformed", body: `{"email":`, wantStatus: http.StatusBadRequest},
+		{name: "empty", body: ``, wantStatus: http.StatusBadRequest},
+		{name: "over the limit", body: `{"email":"user@example.com"}`, maxBytes: 10, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.maxBytes > 0 {
+				r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, tt.maxBytes)
+			}
+
+			var req request
+			err := DecodeJSON(r, &req)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("DecodeJSON = %v, want nil", err)
+				}
+				return
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Status != tt.wantStatus {
+				t.Fatalf("DecodeJSON = %v, want %d api error", err, tt.wantStatus)
+			}
+			if tt.wantField != "" && (len(apiErr.Errors) != 1 || apiErr.Errors[0].Field != tt.wantField) {
+				t.Errorf("field errors = %+v, want %s", apiErr.Errors, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+// CompressionConfig controls gzip response compression
+type CompressionConfig struct {
+	Enabled bool
+	Level   int // gzip level, 1 (fastest) to 9 (smallest)
+	MinSize int // smaller bodies are sent as is, the gzip overhead isn't worth it
+}
+
+var compressionConfig = CompressionConfig{
+	Enabled: true,
+	Level:   5,
+	MinSize: 1024,
+}
+
+func SetCompressionConfig(config CompressionConfig) {
+	compressionConfig = config
+}
+
+func GetCompressionConfig() CompressionConfig {
+	return compressionConfig
+}
@@ -5,4 +5,17 @@ type contextKey int
 const (
 	UserIDKey contextKey = iota
 	EmailKey
+	AuthMethodKey
+	ScopesKey
+	RequestIDKey
+	ClientIdentityKey
+	ClientInfoKey
+)
+
+const (
+	AuthMethodBearer     = "bearer"
+	AuthMethodCookie     = "cookie"
+	AuthMethodAPIKey     = "api_key"
+	AuthMethodClientCert = "client_cert"
+	AuthMethodOAuth      = "oauth" // access token issued by the authorization server
 )
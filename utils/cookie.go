@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CookieConfig controls the optional cookie-based session mode used by browser clients
+type CookieConfig struct {
+	Enabled    bool
+	Name       string
+	CSRFName   string
+	CSRFHeader string
+	Domain     string
+	Path       string
+	Secure     bool
+	SameSite   http.SameSite
+	MaxAge     time.Duration
+}
+
+var cookieConfig = CookieConfig{
+	Enabled:    false,
+	Name:       "access_token",
+	CSRFName:   "csrf_token",
+	CSRFHeader: "X-CSRF-Token",
+	Path:       "/",
+	Secure:     true,
+	SameSite:   http.SameSiteLaxMode,
+	MaxAge:     JWTTokenTTL,
+}
+
+func SetCookieConfig(config CookieConfig) {
+	cookieConfig = config
+}
+
+func GetCookieConfig() CookieConfig {
+	return cookieConfig
+}
+
+// AuthModeHeader lets a client ask for a cookie session when logging in
+const AuthModeHeader = "X-Auth-Mode"
+
+// WantsCookieSession reports whether a login request opted into cookie session
+// mode with "X-Auth-Mode: cookie" or cookie=true, other clients keep getting
+// the bearer token in the body
+func WantsCookieSession(r *http.Request) bool {
+	if !cookieConfig.Enabled {
+		return false
+	}
+	return strings.EqualFold(r.Header.Get(AuthModeHeader), "cookie") || r.FormValue("cookie") == "true"
+}
+
+// ParseSameSite converts "strict", "lax" or "none" to http.SameSite, defaults to lax
+func ParseSameSite(value string) http.SameSite {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// GenerateRandomToken returns n random bytes encoded as url safe base64
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SetAuthCookies sets the HttpOnly session cookie with the JWT token and
+// the readable CSRF cookie used for double-submit protection, returns the CSRF token
+func SetAuthCookies(w http.ResponseWriter, token string) (string, error) {
+	csrfToken, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	maxAge := int(cookieConfig.MaxAge.Seconds())
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieConfig.Name,
+		Value:    token,
+		Path:     cookieConfig.Path,
+		Domain:   cookieConfig.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   cookieConfig.Secure,
+		SameSite: cookieConfig.SameSite,
+	})
+
+	// csrf cookie must be readable by js so the client can echo it in the header
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieConfig.CSRFName,
+		Value:    csrfToken,
+		Path:     cookieConfig.Path,
+		Domain:   cookieConfig.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: false,
+		Secure:   cookieConfig.Secure,
+		SameSite: cookieConfig.SameSite,
+	})
+
+	return csrfToken, nil
+}
+
+// ClearAuthCookies expires both session and CSRF cookies
+func ClearAuthCookies(w http.ResponseWriter) {
+	for _, name := range []string{cookieConfig.Name, cookieConfig.CSRFName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     cookieConfig.Path,
+			Domain:   cookieConfig.Domain,
+			MaxAge:   -1,
+			HttpOnly: name == cookieConfig.Name,
+			Secure:   cookieConfig.Secure,
+			SameSite: cookieConfig.SameSite,
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CORSConfig is the cross-origin policy for browser clients on other origins,
+// CORS is disabled when AllowedOrigins is empty
+type CORSConfig struct {
+	// exact origins like "https://app.example.com", "https://*.example.com"
+	// for any subdomain or "*" for any origin (not allowed with credentials)
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // how long browsers cache preflight results
+}
+
+var corsConfig = CORSConfig{
+	AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+	AllowedHeaders: []string{"Authorization", "Content-Type", "X-API-Key", "X-Auth-Mode", "X-CSRF-Token", "X-Request-ID"},
+	ExposedHeaders: []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
+	MaxAge:         10 * time.Minute,
+}
+
+func SetCORSConfig(config CORSConfig) {
+	corsConfig = config
+}
+
+func GetCORSConfig() CORSConfig {
+	return corsConfig
+}
+
+// MatchOrigin reports whether origin matches one of the allowed origin patterns
+func MatchOrigin(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+
+		// https://*.example.com matches subdomains but not example.com itself
+		scheme, host, ok := strings.Cut(pattern, "://*.")
+		if !ok {
+			continue
+		}
+		prefix := scheme + "://"
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, "."+host) &&
+			len(origin) > len(prefix)+len(host)+1 {
+			// the subdomain part can't smuggle in a port or path
+			subdomain := origin[len(prefix) : len(origin)-len(host)-1]
+			if !strings.ContainsAny(subdomain, ":/@") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidOriginPattern checks an allowed origin pattern, origins have no path
+func ValidOriginPattern(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	u, err := url.Parse(strings.Replace(pattern, "://*.", "://wildcard.", 1))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.User == nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import "testing"
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"exact", []string{"https://app.example.com"}, "https://app.example.com", true},
+		{"case insensitive", []string{"https://App.Example.com"}, "https://app.EXAMPLE.com", true},
+		{"other scheme", []string{"https://app.example.com"}, "http://app.example.com", false},
+		{"other port", []string{"https://app.example.com"}, "https://app.example.com:8443", false},
+		{"any", []string{"*"}, "https://evil.example", true},
+		{"none allowed", nil, "https://app.example.com", false},
+		{"second pattern", []string{"https://a.example.com", "https://b.example.com"}, "https://b.example.com", true},
+		{"wildcard subdomain", []string{"https://*.example.com"}, "https://app.example.com", true},
+		{"wildcard nested subdomain", []string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{"wildcard with port", []string{"https://*.example.com:8443"}, "https://app.example.com:8443", true},
+		{"wildcard excludes apex", []string{"https://*.example.com"}, "https://example.com", false},
+		{"wildcard empty subdomain", []string{"https://*.example.com"}, "https://.example.com", false},
+		{"wildcard other scheme", []string{"https://*.example.com"}, "http://app.example.com", false},
+		{"wildcard suffix lookalike", []string{"https://*.example.com"}, "https://app.evilexample.com", false},
+		{"wildcard smuggled port", []string{"https://*.example.com"}, "https://evil.test:1.example.com", false},
+		{"wildcard smuggled userinfo", []string{"https://*.example.com"}, "https://evil.test@a.example.com", false},
+		{"wildcard smuggled path", []string{"https://*.example.com"}, "https://evil.test/a.example.com", false},
+		{"null origin", []string{"https://app.example.com"}, "null", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchOrigin(tt.allowed, tt.origin); got != tt.want {
+				t.Errorf("MatchOrigin(%q, %q) = %v, want %v", tt.allowed, tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidOriginPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"*", true},
+		{"https://app.example.com", true},
+		{"http://localhost:3000", true},
+		{"https://*.example.com", true},
+		{"app.example.com", false},
+		{"https://app.example.com/", false},
+		{"https://app.example.com/path", false},
+		{"https://user@app.example.com", false},
+		{"ftp://app.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := ValidOriginPattern(tt.pattern); got != tt.want {
+			t.Errorf("ValidOriginPattern(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// stable machine readable error codes
+const (
+	ErrCodeBadRequest           = "bad_request"
+	ErrCodeInvalidBody          = "invalid_body"
+	ErrCodeValidation           = "validation_failed"
+	ErrCodeUnauthorized         = "unauthorized"
+	ErrCodeInvalidCredentials   = "invalid_credentials"
+	ErrCodeInvalidToken         = "invalid_token"
+	ErrCodeTokenExpired         = "token_expired"
+	ErrCodeForbidden            = "forbidden"
+	ErrCodeCSRF                 = "csrf_failed"
+	ErrCodeInsufficientScope    = "insufficient_scope"
+	ErrCodeNotFound             = "not_found"
+	ErrCodeMethodNotAllowed     = "method_not_allowed"
+	ErrCodeConflict             = "conflict"
+	ErrCodePayloadTooLarge      = "payload_too_large"
+	ErrCodeUnsupportedMediaType = "unsupported_media_type"
+	ErrCodeTooManyRequests      = "too_many_requests"
+	ErrCodeInternal             = "internal_error"
+	ErrCodeTimeout              = "timeout"
+	ErrCodeUpstream             = "upstream_error"
+)
+
+// APIError is an RFC 9457 problem details response with a stable code extension
+type APIError struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes a validation failure of a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return e.Code + ": " + e.Detail
+}
+
+func NewAPIError(statusCode int, code, detail string) *APIError {
+	return &APIError{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// NewValidationError returns a 400 error listing every invalid field
+func NewValidationError(fields ...FieldError) *APIError {
+	apiErr := NewAPIError(http.StatusBadRequest, ErrCodeValidation, "Request validation failed")
+	apiErr.Errors = fields
+	return apiErr
+}
+
+// NewPayloadTooLargeError returns a 413 error for a body over limit bytes
+func NewPayloadTooLargeError(limit int64) *APIError {
+	return NewAPIError(http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, "Request body too large, limit is "+strconv.FormatInt(limit, 10)+" bytes")
+}
+
+// WriteError writes err as application/problem+json, errors that are not
+// an APIError are logged and reported as internal server error
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *APIError
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		apiErr = NewPayloadTooLargeError(maxBytesErr.Limit)
+	} else if !errors.As(err, &apiErr) {
+		slog.ErrorContext(r.Context(), "unhandled error", "error", err)
+		apiErr = NewAPIError(http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+	}
+
+	problem := *apiErr
+	problem.Instance = r.URL.Path
+	problem.RequestID = GetRequestID(r.Context())
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// WriteProblem is a shorthand for WriteError(w, r, NewAPIError(statusCode, code, detail))
+func WriteProblem(w http.ResponseWriter, r *http.Request, statusCode int, code, detail string) {
+	WriteError(w, r, NewAPIError(statusCode, code, detail))
+}
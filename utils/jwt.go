@@ -13,6 +13,12 @@ import (
 
 var secretKey []byte // set secure secret key in prod
 
+// ErrTokenExpired is returned by ValidateJWTToken for expired tokens
+var ErrTokenExpired = errors.New("token expired")
+
+// JWTTokenTTL is the lifetime of issued tokens
+const JWTTokenTTL = 24 * time.Hour
+
 func SetJWTSecretKey(key []byte) {
 	secretKey = key
 }
@@ -41,7 +47,7 @@ func GenerateJWTToken(userID int64, email string) (string, error) {
 	claims := JWTClaims{
 		UserID:    userID,
 		Email:     email,
-		ExpiresAt: time.Now().Add(24 * time.Hour).Unix(), // expire in 24 hours
+		ExpiresAt: time.Now().Add(JWTTokenTTL).Unix(),
 	}
 	payloadJSON, err := json.Marshal(claims)
 	if err != nil {
@@ -96,7 +102,7 @@ func ValidateJWTToken(tokenString string) (*JWTClaims, error) {
 
 	// check expiration time
 	if claims.ExpiresAt < time.Now().Unix() {
-		return nil, errors.New("token expired")
+		return nil, ErrTokenExpired
 	}
 
 	return &claims, nil
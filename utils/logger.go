@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
+)
+
+// LogConfig selects the log output format and minimum level
+type LogConfig struct {
+	Format string // "json" (default) or "text"
+	Level  slog.Level
+	Output io.Writer
+}
+
+// sensitive attribute and header names, compared case insensitively
+var redactedKeys = map[string]bool{
+	"password":         true,
+	"new_password":     true,
+	"confirm_password": true,
+	"token":            true,
+	"access_token":     true,
+	"refresh_token":    true,
+	"id_token":         true,
+	"csrf_token":       true,
+	"code_verifier":    true,
+	"secret":           true,
+	"client_secret":    true,
+	"api_key":          true,
+	"authorization":    true,
+	"cookie":           true,
+	"set-cookie":       true,
+	"x-api-key":        true,
+	"x-csrf-token":     true,
+}
+
+const redactedValue = "[REDACTED]"
+
+// SetupLogger installs the default slog logger, stdlib log output goes through it as well
+func SetupLogger(config LogConfig) {
+	if config.Output == nil {
+		config.Output = os.Stderr
+	}
+
+	options := &slog.HandlerOptions{
+		Level:       config.Level,
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(config.Format, "text") {
+		handler = slog.NewTextHandler(config.Output, options)
+	} else {
+		handler = slog.NewJSONHandler(config.Output, options)
+	}
+
+	slog.SetDefault(slog.New(&contextHandler{Handler: handler}))
+}
+
+// ParseLogLevel parses debug, info, warn or error, defaulting to info
+func ParseLogLevel(value string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// redactAttr hides sensitive values by key and sensitive headers in http.Header values
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	if header, ok := attr.Value.Any().(http.Header); ok {
+		redacted := make(map[string]string, len(header))
+		for name, values := range header {
+			if redactedKeys[strings.ToLower(name)] {
+				redacted[name] = redactedValue
+				continue
+			}
+			redacted[name] = strings.Join(values, ", ")
+		}
+		return slog.Any(attr.Key, redacted)
+	}
+
+	if attr.Value.Kind() == slog.KindString && strings.HasPrefix(attr.Value.String(), "Bearer ") {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	return attr
+}
+
+// logFieldsKey holds *LogFields, added by LogsMiddleware so values set deeper
+// in the chain (e.g. the user id after authentication) show up in the access log
+type logFieldsKey struct{}
+
+type LogFields struct {
+	userID atomic.Int64
+}
+
+func ContextWithLogFields(ctx context.Context) context.Context {
+	return context.WithValue(ctx, logFieldsKey{}, &LogFields{})
+}
+
+// SetLogUserID records the authenticated user for every log line of the request
+func SetLogUserID(ctx context.Context, userID int64) {
+	if fields, ok := ctx.Value(logFieldsKey{}).(*LogFields); ok {
+		fields.userID.Store(userID)
+	}
+}
+
+// contextHandler adds request_id, trace_id and user_id from the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := GetRequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+
+	if spanContext := tracing.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID.String()),
+			slog.String("span_id", spanContext.SpanID.String()),
+		)
+	}
+
+	userID, ok := ctx.Value(UserIDKey).(int64)
+	if !ok {
+		if fields, found := ctx.Value(logFieldsKey{}).(*LogFields); found {
+			userID = fields.userID.Load()
+		}
+	}
+	if userID != 0 {
+		record.AddAttrs(slog.Int64("user_id", userID))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "time"
+
+// MagicLinkConfig controls passwordless login links
+type MagicLinkConfig struct {
+	URL         string        // page the emailed link points to, token is appended as query param
+	TTL         time.Duration // link lifetime
+	Window      time.Duration // throttling window
+	MaxPerEmail int           // links per user within window
+	MaxPerIP    int           // links per client ip within window
+}
+
+var magicLinkConfig = MagicLinkConfig{
+	URL:         "http://localhost:8000/api/v1/auth/magic-link/verify",
+	TTL:         15 * time.Minute,
+	Window:      15 * time.Minute,
+	MaxPerEmail: 3,
+	MaxPerIP:    10,
+}
+
+func SetMagicLinkConfig(config MagicLinkConfig) {
+	magicLinkConfig = config
+}
+
+func GetMagicLinkConfig() MagicLinkConfig {
+	return magicLinkConfig
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	OAuthAccessTokenTTL  = time.Hour
+	OAuthRefreshTokenTTL = 30 * 24 * time.Hour
+	OAuthCodeTTL         = time.Minute
+)
+
+const (
+	OAuthGrantAuthorizationCode = "authorization_code"
+	OAuthGrantClientCredentials = "client_credentials"
+	OAuthGrantRefreshToken      = "refresh_token"
+)
+
+const (
+	OAuthTokenTypeAccess  = "access_token"
+	OAuthTokenTypeRefresh = "refresh_token"
+)
+
+// token prefixes tell access tokens apart from JWTs and API keys
+const (
+	OAuthAccessTokenPrefix  = "at_"
+	OAuthRefreshTokenPrefix = "rt_"
+)
+
+var OAuthGrantTypes = []string{OAuthGrantAuthorizationCode, OAuthGrantClientCredentials, OAuthGrantRefreshToken}
+
+// OAuthScopes are the scopes clients may request
+var OAuthScopes = []string{"openid", "profile", "email", "offline_access", APIKeyScopeRead, APIKeyScopeWrite}
+
+var oauthIssuer = "http://localhost:8000"
+
+func SetOAuthIssuer(issuer string) {
+	oauthIssuer = strings.TrimSuffix(issuer, "/")
+}
+
+func GetOAuthIssuer() string {
+	return oauthIssuer
+}
+
+// oauthLoginURL is the frontend login page users without a session are sent
+// to from /oauth/authorize, the authorize url is passed as return_to
+var oauthLoginURL = ""
+
+func SetOAuthLoginURL(loginURL string) {
+	oauthLoginURL = loginURL
+}
+
+func GetOAuthLoginURL() string {
+	return oauthLoginURL
+}
+
+// OAuthError is an RFC 6749 error response
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// IDTokenClaims are the claims of ID tokens issued by this service
+type IDTokenClaims struct {
+	Issuer     string `json:"iss"`
+	Subject    string `json:"sub"`
+	Audience   string `json:"aud"`
+	ExpiresAt  int64  `json:"exp"`
+	IssuedAt   int64  `json:"iat"`
+	AuthTime   int64  `json:"auth_time,omitempty"`
+	Nonce      string `json:"nonce,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Name       string `json:"name,omitempty"`
+	GivenName  string `json:"given_name,omitempty"`
+	FamilyName string `json:"family_name,omitempty"`
+}
+
+// GenerateOAuthToken returns a random token with the given prefix and its storage hash
+func GenerateOAuthToken(prefix string) (token, hash string, err error) {
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	token = prefix + secret
+	return token, HashToken(token), nil
+}
+
+// IsOAuthAccessToken reports whether the token looks like an access token of the authorization server
+func IsOAuthAccessToken(token string) bool {
+	return strings.HasPrefix(token, OAuthAccessTokenPrefix)
+}
+
+// ParseScopes splits a space delimited scope string, dropping duplicates
+func ParseScopes(scope string) []string {
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// ScopesSubset reports whether every requested scope is in allowed
+func ScopesSubset(requested, allowed []string) bool {
+	for _, scope := range requested {
+		if !slices.Contains(allowed, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyPKCE checks the code verifier against the stored S256 challenge
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier)), []byte(challenge)) == 1
+}
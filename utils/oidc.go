@@ -0,0 +1,407 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCProviderConfig configures an external OpenID Connect provider
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client // optional, useful to point at a mock provider
+}
+
+// OIDCDiscovery is the subset of the provider metadata we rely on
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// OIDCTokenResponse is the token endpoint response of the authorization code grant
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// OIDCIDTokenClaims are the validated claims of an ID token
+type OIDCIDTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	AuthorizedBy  string   `json:"azp"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Name          string   `json:"name"`
+	GivenName     string   `json:"given_name"`
+	FamilyName    string   `json:"family_name"`
+}
+
+// audience accepts both string and array forms of the aud claim
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+const oidcCacheTTL = time.Hour
+
+// allowed clock skew when validating ID token times
+const oidcClockSkew = time.Minute
+
+// OIDCProvider is an OpenID Connect relying party for a single provider,
+// discovery document and signing keys are fetched lazily and cached
+type OIDCProvider struct {
+	config OIDCProviderConfig
+
+	mu          sync.Mutex
+	discovery   *OIDCDiscovery
+	keys        JSONWebKeySet
+	refreshedAt time.Time
+}
+
+var (
+	oidcProviders   = map[string]*OIDCProvider{}
+	oidcProvidersMu sync.RWMutex
+)
+
+// RegisterOIDCProvider makes the provider available for social login under its name
+func RegisterOIDCProvider(config OIDCProviderConfig) error {
+	if config.Name == "" || config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return errors.New("oidc provider requires name, issuer, client id and redirect url")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = NewHTTPClient(10 * time.Second)
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	oidcProvidersMu.Lock()
+	defer oidcProvidersMu.Unlock()
+	oidcProviders[config.Name] = &OIDCProvider{config: config}
+	return nil
+}
+
+func GetOIDCProvider(name string) (*OIDCProvider, bool) {
+	oidcProvidersMu.RLock()
+	defer oidcProvidersMu.RUnlock()
+	provider, ok := oidcProviders[name]
+	return provider, ok
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// Discovery returns the cached provider metadata, fetching it when stale
+func (p *OIDCProvider) Discovery(ctx context.Context) (*OIDCDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil && time.Since(p.refreshedAt) < oidcCacheTTL {
+		return p.discovery, nil
+	}
+
+	var discovery OIDCDiscovery
+	err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: missing required endpoints")
+	}
+
+	var keys JSONWebKeySet
+	err = p.getJSON(ctx, discovery.JWKSURI, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	p.discovery = &discovery
+	p.keys = keys
+	p.refreshedAt = time.Now()
+	return p.discovery, nil
+}
+
+// AuthCodeURL builds the authorization request URL with PKCE S256 challenge
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.Discovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange trades the authorization code for tokens
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (*OIDCTokenResponse, error) {
+	discovery, err := p.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.config.ClientID)
+
+	// prefer client_secret_basic unless the provider only supports client_secret_post
+	useBasicAuth := p.config.ClientSecret != "" &&
+		(len(discovery.TokenEndpointAuthMethodsSupported) == 0 ||
+			slices.Contains(discovery.TokenEndpointAuthMethodsSupported, "client_secret_basic"))
+	if p.config.ClientSecret != "" && !useBasicAuth {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	var tokens OIDCTokenResponse
+	err = json.Unmarshal(body, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	return &tokens, nil
+}
+
+// VerifyIDToken validates signature, issuer, audience, expiry and nonce of the ID token
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCIDTokenClaims, error) {
+	_, err := p.Discovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := VerifyJWS(rawIDToken, func(header JWSHeader) (crypto.PublicKey, error) {
+		return p.signingKey(ctx, header.Kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var claims OIDCIDTokenClaims
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case strings.TrimSuffix(claims.Issuer, "/") != p.config.Issuer:
+		return nil, errors.New("id token issuer mismatch")
+	case !slices.Contains(claims.Audience, p.config.ClientID):
+		return nil, errors.New("id token audience mismatch")
+	case len(claims.Audience) > 1 && claims.AuthorizedBy != p.config.ClientID:
+		return nil, errors.New("id token authorized party mismatch")
+	case claims.ExpiresAt == 0 || now.Add(-oidcClockSkew).Unix() > claims.ExpiresAt:
+		return nil, errors.New("id token expired")
+	case claims.IssuedAt > now.Add(oidcClockSkew).Unix():
+		return nil, errors.New("id token issued in the future")
+	case claims.Subject == "":
+		return nil, errors.New("id token has no subject")
+	case !hmac.Equal([]byte(claims.Nonce), []byte(nonce)):
+		return nil, errors.New("id token nonce mismatch")
+	}
+
+	return &claims, nil
+}
+
+// signingKey finds the key by kid, refetching the key set once when the provider rotated keys
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys.Find(kid)
+	jwksURI := p.discovery.JWKSURI
+	p.mu.Unlock()
+
+	if !ok {
+		var keys JSONWebKeySet
+		err := p.getJSON(ctx, jwksURI, &keys)
+		if err != nil {
+			return nil, fmt.Errorf("oidc jwks: %w", err)
+		}
+
+		p.mu.Lock()
+		p.keys = keys
+		p.mu.Unlock()
+
+		key, ok = keys.Find(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+
+	return key.PublicKey()
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", rawURL, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// GeneratePKCE returns a code verifier and its S256 code challenge
+func GeneratePKCE() (verifier, challenge string, err error) {
+	verifier, err = GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, PKCEChallenge(verifier), nil
+}
+
+// PKCEChallenge computes the S256 code challenge for the verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// OIDCState is kept in a signed cookie between the login redirect and the callback
+type OIDCState struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	ExpiresAt    int64  `json:"expires_at"`
+	// CookieSession remembers that the login asked for a cookie session,
+	// the callback is a redirect from the provider and carries no header
+	CookieSession bool `json:"cookie_session,omitempty"`
+}
+
+// EncodeOIDCState serializes and signs the state with the JWT secret key
+func EncodeOIDCState(state OIDCState) (string, error) {
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(stateJSON)
+	return encoded + "." + signValue(encoded), nil
+}
+
+// DecodeOIDCState verifies the signature and expiry of the state cookie value
+func DecodeOIDCState(value string) (*OIDCState, error) {
+	encoded, signature, found := strings.Cut(value, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(signValue(encoded))) {
+		return nil, errors.New("invalid state signature")
+	}
+
+	stateJSON, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var state OIDCState
+	err = json.Unmarshal(stateJSON, &state)
+	if err != nil {
+		return nil, err
+	}
+	if state.ExpiresAt < time.Now().Unix() {
+		return nil, errors.New("state expired")
+	}
+
+	return &state, nil
+}
+
+func signValue(value string) string {
+	h := hmac.New(sha256.New, secretKey)
+	h.Write([]byte("oidc-state:" + value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
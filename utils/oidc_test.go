@@ -0,0 +1,492 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testOIDCClientID     = "test-client"
+	testOIDCClientSecret = "test-secret"
+	testOIDCRedirectURL  = "https://app.example.com/v1/auth/oidc/mock/callback"
+)
+
+// mockOIDCProvider is an OpenID provider with discovery, JWKS, authorization
+// and token endpoints, the authorization endpoint consents right away
+type mockOIDCProvider struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	key       *rsa.PrivateKey
+	kid       string
+	discovery map[string]any
+	claims    map[string]any // added to issued ID tokens
+	codes     map[string]mockOIDCAuthorization
+	requests  map[string]int
+}
+
+type mockOIDCAuthorization struct {
+	codeChallenge string
+	nonce         string
+	redirectURI   string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	m := &mockOIDCProvider{
+		claims:   map[string]any{},
+		codes:    map[string]mockOIDCAuthorization{},
+		requests: map[string]int{},
+	}
+	m.rotateKey(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.handleDiscovery)
+	mux.HandleFunc("GET /jwks", m.handleJWKS)
+	mux.HandleFunc("GET /authorize", m.handleAuthorize)
+	mux.HandleFunc("POST /token", m.handleToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	m.discovery = map[string]any{
+		"issuer":                                m.server.URL,
+		"authorization_endpoint":                m.server.URL + "/authorize",
+		"token_endpoint":                        m.server.URL + "/token",
+		"jwks_uri":                              m.server.URL + "/jwks",
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+	}
+	return m
+}
+
+// rotateKey replaces the signing key, like a provider rotating keys
+func (m *mockOIDCProvider) rotateKey(t *testing.T) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, err := GenerateRandomToken(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	m.key, m.kid = key, kid
+	m.mu.Unlock()
+}
+
+func (m *mockOIDCProvider) count(path string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[path]
+}
+
+func (m *mockOIDCProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.requests[r.URL.Path]++
+	discovery := m.discovery
+	m.mu.Unlock()
+	WriteJson(w, http.StatusOK, discovery)
+}
+
+func (m *mockOIDCProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[r.URL.Path]++
+	WriteJson(w, http.StatusOK, JSONWebKeySet{Keys: []JSONWebKey{{
+		Kty: "RSA",
+		Kid: m.kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+func (m *mockOIDCProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != testOIDCClientID ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code, _ := GenerateRandomToken(16)
+	m.mu.Lock()
+	m.codes[code] = mockOIDCAuthorization{
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		redirectURI:   query.Get("redirect_uri"),
+	}
+	m.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	redirect.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *mockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != testOIDCClientID || clientSecret != testOIDCClientSecret {
+		WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	authorization, ok := m.codes[r.PostFormValue("code")]
+	delete(m.codes, r.PostFormValue("code"))
+	m.mu.Unlock()
+
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != authorization.redirectURI ||
+		PKCEChallenge(r.PostFormValue("code_verifier")) != authorization.codeChallenge {
+		WriteJson(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	WriteJson(w, http.StatusOK, OIDCTokenResponse{
+		AccessToken: "mock-access-token",
+		TokenType:   "Bearer",
+		IDToken:     m.idToken(map[string]any{"nonce": authorization.nonce}),
+		ExpiresIn:   3600,
+	})
+}
+
+// idToken signs an ID token with valid defaults, claims overrides them and
+// a nil value removes the claim
+func (m *mockOIDCProvider) idToken(claims map[string]any) string {
+	now := time.Now()
+	payload := map[string]any{
+		"iss":            m.server.URL,
+		"sub":            "mock-subject",
+		"aud":            testOIDCClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, extra := range []map[string]any{m.claims, claims} {
+		for name, value := range extra {
+			if value == nil {
+				delete(payload, name)
+				continue
+			}
+			payload[name] = value
+		}
+	}
+	return signRS256(m.key, JWSHeader{Alg: "RS256", Kid: m.kid, Typ: "JWT"}, payload)
+}
+
+func signRS256(key *rsa.PrivateKey, header JWSHeader, payload any) string {
+	headerJSON, _ := json.Marshal(header)
+	payloadJSON, _ := json.Marshal(payload)
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// provider registers a relying party for the mock under the test name
+func (m *mockOIDCProvider) provider(t *testing.T) *OIDCProvider {
+	t.Helper()
+	name := t.Name()
+	err := RegisterOIDCProvider(OIDCProviderConfig{
+		Name:         name,
+		Issuer:       m.server.URL,
+		ClientID:     testOIDCClientID,
+		ClientSecret: testOIDCClientSecret,
+		RedirectURL:  testOIDCRedirectURL,
+		HTTPClient:   m.server.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		oidcProvidersMu.Lock()
+		delete(oidcProviders, name)
+		oidcProvidersMu.Unlock()
+	})
+	provider, _ := GetOIDCProvider(name)
+	return provider
+}
+
+// authorize runs the browser part of the flow and returns the code and state
+// the provider redirected back with
+func (m *mockOIDCProvider) authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	client := m.server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d, want 302", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	t.Run("cached", func(t *testing.T) {
+		mock := newMockOIDCProvider(t)
+		provider := mock.provider(t)
+
+		for range 2 {
+			discovery, err := provider.Discovery(context.Background())
+			if err != nil {
+				t.Fatalf("discovery failed: %v", err)
+			}
+			if discovery.TokenEndpoint != mock.server.URL+"/token" {
+				t.Fatalf("token endpoint = %q", discovery.TokenEndpoint)
+			}
+		}
+		if n := mock.count("/.well-known/openid-configuration"); n != 1 {
+			t.Errorf("discovery fetched %d times, want 1", n)
+		}
+		if n := mock.count("/jwks"); n != 1 {
+			t.Errorf("jwks fetched %d times, want 1", n)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		field string
+		value any
+	}{
+		{"issuer mismatch", "issuer", "https://evil.example"},
+		{"missing token endpoint", "token_endpoint", ""},
+		{"missing jwks uri", "jwks_uri", ""},
+		{"unreachable jwks", "jwks_uri", "http://127.0.0.1:1/jwks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockOIDCProvider(t)
+			mock.discovery[tt.field] = tt.value
+			_, err := mock.provider(t).Discovery(context.Background())
+			if err == nil {
+				t.Fatal("discovery succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := mock.provider(t)
+	ctx := context.Background()
+
+	verifier, challenge, err := GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "the-state", "the-nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := mustParseURL(t, authURL).Query()
+	if query.Get("code_challenge") != challenge || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization url has no S256 challenge: %s", authURL)
+	}
+	if scope := query.Get("scope"); !strings.Contains(" "+scope+" ", " openid ") {
+		t.Fatalf("scope %q has no openid", scope)
+	}
+
+	t.Run("wrong verifier", func(t *testing.T) {
+		code, _ := mock.authorize(t, authURL)
+		other, _, _ := GeneratePKCE()
+		_, err := provider.Exchange(ctx, code, other)
+		if err == nil {
+			t.Fatal("exchange with the wrong code verifier succeeded")
+		}
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		code, state := mock.authorize(t, authURL)
+		if state != "the-state" {
+			t.Fatalf("state = %q, want the-state", state)
+		}
+		tokens, err := provider.Exchange(ctx, code, verifier)
+		if err != nil {
+			t.Fatalf("exchange failed: %v", err)
+		}
+		claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, "the-nonce")
+		if err != nil {
+			t.Fatalf("id token rejected: %v", err)
+		}
+		if claims.Subject != "mock-subject" || claims.Email != "user@example.com" || !claims.EmailVerified {
+			t.Fatalf("unexpected claims %+v", claims)
+		}
+
+		// codes are single use
+		_, err = provider.Exchange(ctx, code, verifier)
+		if err == nil {
+			t.Fatal("authorization code was accepted twice")
+		}
+	})
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := mock.provider(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		token   func(valid string) string
+		wantErr bool
+	}{
+		{name: "valid", claims: map[string]any{}},
+		{name: "issuer with trailing slash", claims: map[string]any{"iss": mock.server.URL + "/"}},
+		{name: "wrong issuer", claims: map[string]any{"iss": "https://evil.example"}, wantErr: true},
+		{name: "wrong audience", claims: map[string]any{"aud": "other-client"}, wantErr: true},
+		{name: "audience list", claims: map[string]any{"aud": []string{testOIDCClientID, "other"}, "azp": testOIDCClientID}},
+		{name: "audience list without azp", claims: map[string]any{"aud": []string{testOIDCClientID, "other"}}, wantErr: true},
+		{name: "expired", claims: map[string]any{"exp": now.Add(-2 * oidcClockSkew).Unix()}, wantErr: true},
+		{name: "expired within clock skew", claims: map[string]any{"exp": now.Add(-oidcClockSkew / 2).Unix()}},
+		{name: "no expiry", claims: map[string]any{"exp": nil}, wantErr: true},
+		{name: "issued in the future", claims: map[string]any{"iat": now.Add(2 * oidcClockSkew).Unix()}, wantErr: true},
+		{name: "no subject", claims: map[string]any{"sub": nil}, wantErr: true},
+		{name: "nonce mismatch", claims: map[string]any{"nonce": "other-nonce"}, wantErr: true},
+		{name: "no nonce", claims: map[string]any{"nonce": nil}, wantErr: true},
+		{
+			name: "unknown key",
+			token: func(string) string {
+				return signRS256(otherKey, JWSHeader{Alg: "RS256", Kid: "unknown"}, map[string]any{"iss": mock.server.URL})
+			},
+			wantErr: true,
+		},
+		{
+			name: "signed by other key",
+			token: func(valid string) string {
+				header, _, _ := strings.Cut(valid, ".")
+				headerJSON, _ := base64.RawURLEncoding.DecodeString(header)
+				var jwsHeader JWSHeader
+				json.Unmarshal(headerJSON, &jwsHeader)
+				return signRS256(otherKey, jwsHeader, map[string]any{
+					"iss": mock.server.URL, "sub": "mock-subject", "aud": testOIDCClientID,
+					"exp": now.Add(time.Hour).Unix(), "nonce": "the-nonce",
+				})
+			},
+			wantErr: true,
+		},
+		{
+			name: "alg none",
+			token: func(valid string) string {
+				parts := strings.Split(valid, ".")
+				header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+				return header + "." + parts[1] + "."
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]any{"nonce": "the-nonce"}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			token := mock.idToken(claims)
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			_, err := provider.VerifyIDToken(ctx, token, "the-nonce")
+			if tt.wantErr && err == nil {
+				t.Fatal("id token accepted, want error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("id token rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestOIDCVerifyIDTokenKeyRotation(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := mock.provider(t)
+	ctx := context.Background()
+
+	_, err := provider.Discovery(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.rotateKey(t)
+
+	_, err = provider.VerifyIDToken(ctx, mock.idToken(map[string]any{"nonce": "n"}), "n")
+	if err != nil {
+		t.Fatalf("token signed with the rotated key rejected: %v", err)
+	}
+	if n := mock.count("/jwks"); n != 2 {
+		t.Errorf("jwks fetched %d times, want 2", n)
+	}
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	got := PKCEChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Fatalf("PKCEChallenge = %q, want %q", got, want)
+	}
+}
+
+func TestOIDCState(t *testing.T) {
+	state := OIDCState{Provider: "mock", State: "s", Nonce: "n", CodeVerifier: "v", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	encoded, err := EncodeOIDCState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeOIDCState(encoded)
+	if err != nil || *decoded != state {
+		t.Fatalf("DecodeOIDCState = %+v, %v, want %+v", decoded, err, state)
+	}
+
+	tampered := "x" + encoded[1:]
+	if _, err := DecodeOIDCState(tampered); err == nil {
+		t.Error("tampered state accepted")
+	}
+
+	state.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	expired, _ := EncodeOIDCState(state)
+	if _, err := DecodeOIDCState(expired); err == nil {
+		t.Error("expired state accepted")
+	}
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
@@ -1,16 +1,27 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"strings"
+	"time"
 	"unicode"
 
+	"github.com/olksndrdevhub/go-api-starter-kit/metrics"
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
 	"golang.org/x/crypto/argon2"
 )
 
+var passwordHashDuration = metrics.NewHistogramVec(
+	"password_hash_duration_seconds",
+	"Argon2id key derivation duration by operation.",
+	[]float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	"operation",
+)
+
 type passwordConfig struct {
 	memory      uint32
 	iterations  uint32
@@ -93,7 +104,7 @@ func ValidatePassword(password string) error {
 }
 
 // HashPassword generates a secure hash from the password
-func HashPassword(password string) (string, error) {
+func HashPassword(ctx context.Context, password string) (string, error) {
 	// random salt
 	salt := make([]byte, config.saltLength)
 	_, err := rand.Read(salt)
@@ -102,6 +113,9 @@ func HashPassword(password string) (string, error) {
 	}
 
 	// hash password using argon2id
+	_, span := tracing.Start(ctx, "password.hash")
+	defer span.End()
+	startTime := time.Now()
 	hash := argon2.IDKey(
 		[]byte(password),
 		salt,
@@ -110,6 +124,7 @@ func HashPassword(password string) (string, error) {
 		config.parallelism,
 		config.keyLength,
 	)
+	passwordHashDuration.WithLabelValues("hash").Observe(time.Since(startTime).Seconds())
 
 	// format the hash with its params for storage
 	encodedHash := fmt.Sprintf(
@@ -126,7 +141,12 @@ func HashPassword(password string) (string, error) {
 
 }
 
-func VerifyPassword(password, encodedHash string) (bool, error) {
+func VerifyPassword(ctx context.Context, password, encodedHash string) (bool, error) {
+	// accounts created through social login have no password
+	if encodedHash == "" {
+		return false, nil
+	}
+
 	// extract the parameters from the encoded hash
 	parts := strings.Split(encodedHash, "$")
 	if len(parts) != 6 {
@@ -164,6 +184,9 @@ func VerifyPassword(password, encodedHash string) (bool, error) {
 	keyLength := uint32(len(storedHash))
 
 	// compute hash from provided password with same parameters
+	_, span := tracing.Start(ctx, "password.verify")
+	defer span.End()
+	startTime := time.Now()
 	computedHash := argon2.IDKey(
 		[]byte(password),
 		salt,
@@ -172,6 +195,7 @@ func VerifyPassword(password, encodedHash string) (bool, error) {
 		parallelism,
 		keyLength,
 	)
+	passwordHashDuration.WithLabelValues("verify").Observe(time.Since(startTime).Seconds())
 
 	return subtle.ConstantTimeCompare(storedHash, computedHash) == 1, nil
 
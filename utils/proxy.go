@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ProxyConfig lists the proxies whose forwarding headers are trusted,
+// requests from other peers keep their socket address as client ip
+type ProxyConfig struct {
+	TrustedProxies []netip.Prefix
+	// TrustUnixSocket trusts peers on a unix socket listener, e.g. a local nginx
+	TrustUnixSocket bool
+}
+
+var proxyConfig = ProxyConfig{}
+
+func SetProxyConfig(config ProxyConfig) {
+	proxyConfig = config
+}
+
+func GetProxyConfig() ProxyConfig {
+	return proxyConfig
+}
+
+// ParseTrustedProxies parses CIDRs and single addresses, "unix" trusts unix
+// socket peers and "private" expands to loopback and private network ranges
+func ParseTrustedProxies(values []string) (ProxyConfig, error) {
+	var config ProxyConfig
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+		case value == "unix":
+			config.TrustUnixSocket = true
+		case value == "private":
+			for _, cidr := range []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7"} {
+				config.TrustedProxies = append(config.TrustedProxies, netip.MustParsePrefix(cidr))
+			}
+		case strings.Contains(value, "/"):
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return ProxyConfig{}, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			config.TrustedProxies = append(config.TrustedProxies, prefix.Masked())
+		default:
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return ProxyConfig{}, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			config.TrustedProxies = append(config.TrustedProxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return config, nil
+}
+
+// Trusts reports whether addr belongs to a trusted proxy
+func (c ProxyConfig) Trusts(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range c.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientInfo is the client as seen by the first trusted proxy, or the
+// socket peer when the request did not come through one
+type ClientInfo struct {
+	IP     string // without port
+	Scheme string // http or https
+	Host   string // requested host, may include a port
+}
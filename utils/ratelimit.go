@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RateLimitTokenBucket allows bursts up to Burst requests and refills
+	// Limit tokens per Window, good for API clients
+	RateLimitTokenBucket = "token_bucket"
+	// RateLimitSlidingWindow allows Limit requests in any Window, weighting
+	// the previous fixed window, good for login and register
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// RateLimitPolicy limits requests per key, a policy with Limit 0 is disabled
+type RateLimitPolicy struct {
+	Name      string // prefixes store keys, policies don't share counters
+	Algorithm string
+	Limit     int
+	Window    time.Duration
+	Burst     int // token bucket size, Limit when 0
+}
+
+// RateLimitState is the stored state of one key. Token bucket keeps the
+// tokens left in Value and the last refill in Time, sliding window keeps the
+// current and previous window counts and the current window start
+type RateLimitState struct {
+	Value     float64
+	Previous  float64
+	Time      time.Time
+	ExpiresAt time.Time // the state is the same as a fresh one after this
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the limit is fully restored
+	RetryAfter time.Duration // until the next request is allowed, when denied
+}
+
+// RateLimitStore keeps rate limit states, Take must update the state of a
+// key atomically so concurrent requests can't both take the last token
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error)
+}
+
+// ParseRateLimit parses a rate like "10/1m" into limit and window
+func ParseRateLimit(s string) (int, time.Duration, error) {
+	limitStr, windowStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("rate %q must look like 10/1m", s)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limit < 0 {
+		return 0, 0, fmt.Errorf("rate %q has an invalid limit", s)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+	if err != nil || window <= 0 {
+		return 0, 0, fmt.Errorf("rate %q has an invalid window", s)
+	}
+	return limit, window, nil
+}
+
+// Take applies one request to state and returns the new state, stores call
+// it while holding the key
+func (p RateLimitPolicy) Take(state RateLimitState, now time.Time) (RateLimitState, RateLimitResult) {
+	if p.Algorithm == RateLimitSlidingWindow {
+		return p.takeSlidingWindow(state, now)
+	}
+	return p.takeTokenBucket(state, now)
+}
+
+func (p RateLimitPolicy) takeTokenBucket(state RateLimitState, now time.Time) (RateLimitState, RateLimitResult) {
+	capacity := float64(p.Limit)
+	if p.Burst > 0 {
+		capacity = float64(p.Burst)
+	}
+	perSecond := float64(p.Limit) / p.Window.Seconds()
+
+	tokens := capacity
+	if !state.Time.IsZero() {
+		elapsed := max(now.Sub(state.Time).Seconds(), 0)
+		tokens = min(capacity, state.Value+elapsed*perSecond)
+	}
+
+	result := RateLimitResult{Limit: int(capacity)}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / perSecond)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = seconds((capacity - tokens) / perSecond)
+
+	state = RateLimitState{Value: tokens, Time: now, ExpiresAt: now.Add(result.Reset)}
+	return state, result
+}
+
+func (p RateLimitPolicy) takeSlidingWindow(state RateLimitState, now time.Time) (RateLimitState, RateLimitResult) {
+	windowStart := now.Truncate(p.Window)
+	switch {
+	case state.Time.Equal(windowStart):
+	case state.Time.Equal(windowStart.Add(-p.Window)):
+		state.Previous, state.Value = state.Value, 0
+	default:
+		state.Previous, state.Value = 0, 0
+	}
+	state.Time = windowStart
+
+	// the previous window counts less the further the current one progressed
+	elapsed := now.Sub(windowStart).Seconds() / p.Window.Seconds()
+	count := state.Previous*(1-elapsed) + state.Value
+	limit := float64(p.Limit)
+
+	result := RateLimitResult{Limit: p.Limit}
+	if count+1 <= limit {
+		state.Value++
+		count++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = p.slidingRetryAfter(state, windowStart, now)
+	}
+	result.Remaining = max(int(limit-count), 0)
+	result.Reset = windowStart.Add(p.Window).Sub(now)
+	if state.Value > 0 {
+		// the current count still weighs on the next window
+		result.Reset += p.Window
+	}
+	state.ExpiresAt = windowStart.Add(2 * p.Window)
+	return state, result
+}
+
+// slidingRetryAfter finds when the weighted count leaves room for one request
+func (p RateLimitPolicy) slidingRetryAfter(state RateLimitState, windowStart, now time.Time) time.Duration {
+	room := float64(p.Limit) - 1
+	window := p.Window.Seconds()
+	if state.Value <= room && state.Previous > 0 {
+		// previous*(1-x) + value <= room within the current window
+		x := 1 - (room-state.Value)/state.Previous
+		return max(windowStart.Add(seconds(x*window)).Sub(now), time.Second)
+	}
+	if state.Value == 0 {
+		return windowStart.Add(p.Window).Sub(now)
+	}
+	// value*(1-x) <= room within the next window
+	x := max(1-room/state.Value, 0)
+	return windowStart.Add(p.Window + seconds(x*window)).Sub(now)
+}
+
+// seconds rounds up to whole milliseconds, ignoring float noise
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s*1000-1e-6)) * time.Millisecond
+}
+
+// MemoryRateLimitStore keeps states in process memory, limits are per instance.
+// Expired states are swept periodically, when MaxKeys is still exceeded new
+// keys evict the least recently used ones so memory stays bounded under key
+// flooding
+type MemoryRateLimitStore struct {
+	MaxKeys int
+
+	mu        sync.Mutex
+	states    map[string]*list.Element
+	recent    *list.List // of *memoryRateLimitEntry, most recently used first
+	lastSweep time.Time
+}
+
+type memoryRateLimitEntry struct {
+	key   string
+	state RateLimitState
+}
+
+func NewMemoryRateLimitStore(maxKeys int) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{MaxKeys: maxKeys, states: make(map[string]*list.Element), recent: list.New()}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= time.Minute {
+		s.sweep(now)
+	}
+
+	element, ok := s.states[key]
+	if !ok {
+		for s.MaxKeys > 0 && len(s.states) >= s.MaxKeys {
+			s.remove(s.recent.Back())
+		}
+		element = s.recent.PushFront(&memoryRateLimitEntry{key: key})
+		s.states[key] = element
+	} else {
+		s.recent.MoveToFront(element)
+	}
+
+	entry := element.Value.(*memoryRateLimitEntry)
+	if now.After(entry.state.ExpiresAt) {
+		entry.state = RateLimitState{}
+	}
+	state, result := policy.Take(entry.state, now)
+	entry.state = state
+	return result, nil
+}
+
+func (s *MemoryRateLimitStore) remove(element *list.Element) {
+	s.recent.Remove(element)
+	delete(s.states, element.Value.(*memoryRateLimitEntry).key)
+}
+
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for element := s.recent.Front(); element != nil; {
+		next := element.Next()
+		if now.After(element.Value.(*memoryRateLimitEntry).state.ExpiresAt) {
+			s.remove(element)
+		}
+		element = next
+	}
+	s.lastSweep = now
+}
+
+// RateLimitConfig holds the store and the per route policies
+type RateLimitConfig struct {
+	Store    RateLimitStore
+	Login    RateLimitPolicy // per client ip
+	Register RateLimitPolicy // per client ip
+	Auth     RateLimitPolicy // all unauthenticated auth routes, per client ip
+	API      RateLimitPolicy // authenticated routes, per API key or user
+}
+
+var rateLimitConfig = RateLimitConfig{
+	Store:    NewMemoryRateLimitStore(100_000),
+	Login:    RateLimitPolicy{Name: "login", Algorithm: RateLimitSlidingWindow, Limit: 10, Window: time.Minute},
+	Register: RateLimitPolicy{Name: "register", Algorithm: RateLimitSlidingWindow, Limit: 5, Window: time.Hour},
+	Auth:     RateLimitPolicy{Name: "auth", Algorithm: RateLimitTokenBucket, Limit: 60, Window: time.Minute},
+	API:      RateLimitPolicy{Name: "api", Algorithm: RateLimitTokenBucket, Limit: 600, Window: time.Minute, Burst: 100},
+}
+
+func SetRateLimitConfig(config RateLimitConfig) {
+	rateLimitConfig = config
+}
+
+func GetRateLimitConfig() RateLimitConfig {
+	return rateLimitConfig
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value      string
+		wantLimit  int
+		wantWindow time.Duration
+		wantErr    bool
+	}{
+		{value: "10/1m", wantLimit: 10, wantWindow: time.Minute},
+		{value: " 5 / 1h ", wantLimit: 5, wantWindow: time.Hour},
+		{value: "0/1s", wantLimit: 0, wantWindow: time.Second},
+		{value: "10", wantErr: true},
+		{value: "/1m", wantErr: true},
+		{value: "x/1m", wantErr: true},
+		{value: "-1/1m", wantErr: true},
+		{value: "10/", wantErr: true},
+		{value: "10/0s", wantErr: true},
+		{value: "10/-1s", wantErr: true},
+		{value: "10/soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		limit, window, err := ParseRateLimit(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRateLimit(%q) = %d, %s, want error", tt.value, limit, window)
+			}
+			continue
+		}
+		if err != nil || limit != tt.wantLimit || window != tt.wantWindow {
+			t.Errorf("ParseRateLimit(%q) = %d, %s, %v, want %d, %s", tt.value, limit, window, err, tt.wantLimit, tt.wantWindow)
+		}
+	}
+}
+
+// rateLimitStep is one request at offset from the start of a window
+type rateLimitStep struct {
+	offset         time.Duration
+	wantAllowed    bool
+	wantRemaining  int
+	wantRetryAfter time.Duration // checked when denied
+	wantReset      time.Duration // checked when not zero
+}
+
+func runRateLimitSteps(t *testing.T, policy RateLimitPolicy, steps []rateLimitStep) {
+	t.Helper()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var state RateLimitState
+	for i, step := range steps {
+		var result RateLimitResult
+		state, result = policy.Take(state, start.Add(step.offset))
+		if result.Allowed != step.wantAllowed || result.Remaining != step.wantRemaining {
+			t.Fatalf("step %d at %s: allowed %v remaining %d, want %v and %d",
+				i, step.offset, result.Allowed, result.Remaining, step.wantAllowed, step.wantRemaining)
+		}
+		if !step.wantAllowed && result.RetryAfter != step.wantRetryAfter {
+			t.Errorf("step %d at %s: retry after %s, want %s", i, step.offset, result.RetryAfter, step.wantRetryAfter)
+		}
+		if step.wantReset != 0 && result.Reset != step.wantReset {
+			t.Errorf("step %d at %s: reset %s, want %s", i, step.offset, result.Reset, step.wantReset)
+		}
+	}
+}
+
+func TestTokenBucketTake(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RateLimitPolicy
+		steps  []rateLimitStep
+	}{
+		{
+			name:   "burst then refill",
+			policy: RateLimitPolicy{Algorithm: RateLimitTokenBucket, Limit: 3, Window: 3 * time.Second},
+			steps: []rateLimitStep{
+				{offset: 0, wantAllowed: true, wantRemaining: 2, wantReset: time.Second},
+				{offset: 0, wantAllowed: true, wantRemaining: 1},
+				{offset: 0, wantAllowed: true, wantRemaining: 0, wantReset: 3 * time.Second},
+				{offset: 0, wantAllowed: false, wantRemaining: 0, wantRetryAfter: time.Second},
+				{offset: 500 * time.Millisecond, wantAllowed: false, wantRemaining: 0, wantRetryAfter: 500 * time.Millisecond},
+				{offset: time.Second, wantAllowed: true, wantRemaining: 0},
+				{offset: 10 * time.Second, wantAllowed: true, wantRemaining: 2},
+			},
+		},
+		{
+			name:   "burst larger than the rate",
+			policy: RateLimitPolicy{Algorithm: RateLimitTokenBucket, Limit: 1, Window: time.Second, Burst: 3},
+			steps: []rateLimitStep{
+				{offset: 0, wantAllowed: true, wantRemaining: 2},
+				{offset: 0, wantAllowed: true, wantRemaining: 1},
+				{offset: 0, wantAllowed: true, wantRemaining: 0},
+				{offset: 0, wantAllowed: false, wantRemaining: 0, wantRetryAfter: time.Second},
+				{offset: 2 * time.Second, wantAllowed: true, wantRemaining: 1},
+			},
+		},
+		{
+			name:   "clock going backwards",
+			policy: RateLimitPolicy{Algorithm: RateLimitTokenBucket, Limit: 1, Window: time.Minute},
+			steps: []rateLimitStep{
+				{offset: time.Minute, wantAllowed: true, wantRemaining: 0},
+				{offset: 0, wantAllowed: false, wantRemaining: 0, wantRetryAfter: time.Minute},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runRateLimitSteps(t, tt.policy, tt.steps)
+		})
+	}
+}
+
+func TestSlidingWindowTake(t *testing.T) {
+	policy := RateLimitPolicy{Algorithm: RateLimitSlidingWindow, Limit: 4, Window: time.Minute}
+
+	tests := []struct {
+		name  string
+		steps []rateLimitStep
+	}{
+		{
+			name: "limit within one window",
+			steps: []rateLimitStep{
+				{offset: 0, wantAllowed: true, wantRemaining: 3, wantReset: 2 * time.Minute},
+				{offset: 10 * time.Second, wantAllowed: true, wantRemaining: 2},
+				{offset: 20 * time.Second, wantAllowed: true, wantRemaining: 1},
+				{offset: 30 * time.Second, wantAllowed: true, wantRemaining: 0},
+				// the full current window weighs on the next one until it is a quarter through
+				{offset: 40 * time.Second, wantAllowed: false, wantRemaining: 0, wantRetryAfter: 35 * time.Second},
+			},
+		},
+		{
+			name: "previous window weighs on the current one",
+			steps: []rateLimitStep{
+				{offset: 0, wantAllowed: true, wantRemaining: 3},
+				{offset: 0, wantAllowed: true, wantRemaining: 2},
+				{offset: 0, wantAllowed: true, wantRemaining: 1},
+				{offset: 0, wantAllowed: true, wantRemaining: 0},
+				// half way through the next window the previous 4 count as 2
+				{offset: 90 * time.Second, wantAllowed: true, wantRemaining: 1},
+				{offset: 90 * time.Second, wantAllowed: true, wantRemaining: 0},
+				{offset: 90 * time.Second, wantAllowed: false, wantRemaining: 0, wantRetryAfter: 15 * time.Second},
+				{offset: 105 * time.Second, wantAllowed: true, wantRemaining: 0},
+			},
+		},
+		{
+			name: "old windows are forgotten",
+			steps: []rateLimitStep{
+				{offset: 0, wantAllowed: true, wantRemaining: 3},
+				{offset: 0, wantAllowed: true, wantRemaining: 2},
+				{offset: 0, wantAllowed: true, wantRemaining: 1},
+				{offset: 0, wantAllowed: true, wantRemaining: 0},
+				{offset: 3 * time.Minute, wantAllowed: true, wantRemaining: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runRateLimitSteps(t, policy, tt.steps)
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryRateLimitStore(3)
+	policy := RateLimitPolicy{Name: "test", Algorithm: RateLimitSlidingWindow, Limit: 1, Window: time.Hour}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	take := func(key string) bool {
+		result, err := store.Take(ctx, key, policy, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Allowed
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		take(key)
+	}
+	take("a") // denied, but "a" is now the most recently used key
+	take("d") // evicts "b"
+
+	if len(store.states) != 3 || store.recent.Len() != 3 {
+		t.Fatalf("store holds %d keys, %d list entries, want 3", len(store.states), store.recent.Len())
+	}
+	if take("a") || take("c") || take("d") {
+		t.Error("kept keys lost their state")
+	}
+	if !take("b") {
+		t.Error("evicted key still limited")
+	}
+}
+
+func TestMemoryRateLimitStoreSweepsExpiredStates(t *testing.T) {
+	store := NewMemoryRateLimitStore(0)
+	policy := RateLimitPolicy{Name: "test", Algorithm: RateLimitTokenBucket, Limit: 10, Window: time.Second}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := range 100 {
+		store.Take(context.Background(), fmt.Sprint(i), policy, now)
+	}
+	// the sweep runs at most once a minute
+	store.Take(context.Background(), "late", policy, now.Add(30*time.Second))
+	if len(store.states) != 101 {
+		t.Fatalf("store holds %d keys before the sweep interval, want 101", len(store.states))
+	}
+
+	store.Take(context.Background(), "later", policy, now.Add(2*time.Minute))
+	if len(store.states) != 1 || store.recent.Len() != 1 {
+		t.Fatalf("store holds %d keys after the sweep, want only the new one", len(store.states))
+	}
+}
+
+func BenchmarkMemoryRateLimitStoreAtCapacity(b *testing.B) {
+	store := NewMemoryRateLimitStore(100_000)
+	policy := RateLimitPolicy{Name: "bench", Algorithm: RateLimitTokenBucket, Limit: 10, Window: time.Hour}
+	now := time.Now()
+	for i := range 100_000 {
+		store.Take(context.Background(), fmt.Sprint(i), policy, now)
+	}
+
+	b.ResetTimer()
+	for i := range b.N {
+		store.Take(context.Background(), fmt.Sprint("new", i), policy, now)
+	}
+}
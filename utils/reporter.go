@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ErrorEvent describes an unexpected failure, e.g. a recovered panic
+type ErrorEvent struct {
+	Err       error
+	Stack     []byte
+	RequestID string
+	Method    string
+	Path      string
+	Time      time.Time
+}
+
+// ErrorReporter forwards error events to a tracking backend (Sentry, Rollbar...)
+type ErrorReporter interface {
+	Report(ctx context.Context, event ErrorEvent)
+}
+
+// NopReporter discards events, used when no backend is configured
+// (recovered panics are still logged by the middleware)
+type NopReporter struct{}
+
+func (NopReporter) Report(ctx context.Context, event ErrorEvent) {}
+
+// FakeReporter keeps error events in memory so tests can assert on them
+type FakeReporter struct {
+	mu     sync.Mutex
+	events []ErrorEvent
+}
+
+func (f *FakeReporter) Report(ctx context.Context, event ErrorEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+}
+
+// Events returns a copy of the reported events
+func (f *FakeReporter) Events() []ErrorEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ErrorEvent(nil), f.events...)
+}
+
+var errorReporter ErrorReporter = NopReporter{}
+
+func SetErrorReporter(reporter ErrorReporter) {
+	errorReporter = reporter
+}
+
+func GetErrorReporter() ErrorReporter {
+	return errorReporter
+}
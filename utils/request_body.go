@@ -0,0 +1,20 @@
+package utils
+
+// RequestBodyConfig limits what clients can send in request bodies
+type RequestBodyConfig struct {
+	MaxBytes  int64 // default limit, routes can override it with middleware.BodyLimit
+	AllowGzip bool  // accept Content-Encoding: gzip, the limit applies to decompressed bytes
+}
+
+var requestBodyConfig = RequestBodyConfig{
+	MaxBytes:  1 << 20, // 1 MiB
+	AllowGzip: true,
+}
+
+func SetRequestBodyConfig(config RequestBodyConfig) {
+	requestBodyConfig = config
+}
+
+func GetRequestBodyConfig() RequestBodyConfig {
+	return requestBodyConfig
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"time"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/tracing"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// crockford base32 alphabet used by ULIDs
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a 26 character ULID, 48 bit millisecond timestamp followed
+// by 80 random bits, so ids sort by creation time and don't collide under load
+func NewULID() string {
+	var data [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 5; i >= 0; i-- {
+		data[i] = byte(ms)
+		ms >>= 8
+	}
+	rand.Read(data[6:])
+
+	// 128 bits encoded 5 bits at a time, the first character holds the top 3 bits
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		bit := (25 - i) * 5
+		index := 15 - bit/8
+		shift := bit % 8
+		value := uint16(data[index]) >> shift
+		if shift > 3 && index > 0 {
+			value |= uint16(data[index-1]) << (8 - shift)
+		}
+		out[i] = ulidAlphabet[value&0x1f]
+	}
+	return string(out[:])
+}
+
+// ValidRequestID accepts ids from upstream proxies and clients that are short
+// and only use characters safe to log and echo in headers
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// GetRequestID returns the request id stored in ctx by the request id middleware
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDKey).(string)
+	return requestID
+}
+
+// RequestIDTransport forwards the request id of the outbound request context
+// so calls to other services can be correlated with ours
+type RequestIDTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	requestID := GetRequestID(req.Context())
+	if requestID == "" || req.Header.Get(RequestIDHeader) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, requestID)
+	return base.RoundTrip(req)
+}
+
+// NewHTTPClient returns a client for outbound calls that propagates the
+// request id and trace context
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &RequestIDTransport{Base: &tracing.Transport{}},
+	}
+}
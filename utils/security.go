@@ -0,0 +1,87 @@
+package utils
+
+// SecurityHeaders is a set of response security headers, empty values are not sent
+type SecurityHeaders struct {
+	ContentTypeOptions        string // X-Content-Type-Options
+	FrameOptions              string // X-Frame-Options
+	ReferrerPolicy            string
+	ContentSecurityPolicy     string
+	PermissionsPolicy         string
+	CrossOriginOpenerPolicy   string
+	CrossOriginResourcePolicy string
+	CrossOriginEmbedderPolicy string
+}
+
+// SecurityHeadersConfig holds the policy of every route group, Default
+// applies to all responses and the group policies replace it for their routes.
+// HSTS is configured in TLSConfig because it only applies to TLS responses
+type SecurityHeadersConfig struct {
+	Default SecurityHeaders
+	API     SecurityHeaders
+	OAuth   SecurityHeaders
+	Admin   SecurityHeaders
+}
+
+// DefaultPermissionsPolicy disables powerful browser features, only passkey
+// login on the same origin stays allowed
+const DefaultPermissionsPolicy = "accelerometer=(), camera=(), display-capture=(), geolocation=(), gyroscope=(), " +
+	"magnetometer=(), microphone=(), payment=(), publickey-credentials-get=(self), usb=()"
+
+// defaultSecurityHeaders is strict, API responses and the small built-in
+// pages (magic link, oauth consent) need no scripts, styles or framing
+var defaultSecurityHeaders = SecurityHeaders{
+	ContentTypeOptions:        "nosniff",
+	FrameOptions:              "DENY",
+	ReferrerPolicy:            "no-referrer",
+	ContentSecurityPolicy:     "default-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'",
+	PermissionsPolicy:         DefaultPermissionsPolicy,
+	CrossOriginOpenerPolicy:   "same-origin",
+	CrossOriginResourcePolicy: "same-origin",
+}
+
+var securityHeadersConfig = SecurityHeadersConfig{
+	Default: defaultSecurityHeaders,
+	API:     DefaultAPISecurityHeaders(),
+	OAuth:   DefaultOAuthSecurityHeaders(),
+	Admin:   DefaultAdminSecurityHeaders(),
+}
+
+func DefaultSecurityHeaders() SecurityHeaders {
+	return defaultSecurityHeaders
+}
+
+// DefaultAPISecurityHeaders is the default policy plus COEP, JSON responses
+// never embed cross origin resources. CORP does not affect CORS requests
+func DefaultAPISecurityHeaders() SecurityHeaders {
+	headers := defaultSecurityHeaders
+	headers.CrossOriginEmbedderPolicy = "require-corp"
+	return headers
+}
+
+// DefaultOAuthSecurityHeaders drops form-action, the consent form is answered
+// with a redirect to the client's redirect_uri on another origin and browsers
+// applying form-action to redirects would block it
+func DefaultOAuthSecurityHeaders() SecurityHeaders {
+	headers := defaultSecurityHeaders
+	headers.ContentSecurityPolicy = "default-src 'none'; base-uri 'none'; frame-ancestors 'none'"
+	return headers
+}
+
+// DefaultAdminSecurityHeaders is relaxed for a browser UI served from the
+// same origin: own scripts, styles, images and same origin framing
+func DefaultAdminSecurityHeaders() SecurityHeaders {
+	headers := defaultSecurityHeaders
+	headers.FrameOptions = "SAMEORIGIN"
+	headers.ReferrerPolicy = "strict-origin-when-cross-origin"
+	headers.ContentSecurityPolicy = "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; " +
+		"base-uri 'self'; form-action 'self'; frame-ancestors 'self'"
+	return headers
+}
+
+func SetSecurityHeadersConfig(config SecurityHeadersConfig) {
+	securityHeadersConfig = config
+}
+
+func GetSecurityHeadersConfig() SecurityHeadersConfig {
+	return securityHeadersConfig
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "time"
+
+// ServerConfig holds http.Server limits, the defaults protect against
+// slow clients holding connections open (slowloris)
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration // headers and body
+	WriteTimeout      time.Duration // from end of headers to end of response, keep above HandlerTimeout
+	IdleTimeout       time.Duration // keep-alive connections
+	MaxHeaderBytes    int
+	HandlerTimeout    time.Duration // default for middleware.Timeout, 0 disables it
+}
+
+var serverConfig = ServerConfig{
+	ReadHeaderTimeout: 5 * time.Second,
+	ReadTimeout:       30 * time.Second,
+	WriteTimeout:      60 * time.Second,
+	IdleTimeout:       120 * time.Second,
+	MaxHeaderBytes:    64 << 10, // 64 KiB
+	HandlerTimeout:    30 * time.Second,
+}
+
+func SetServerConfig(config ServerConfig) {
+	serverConfig = config
+}
+
+func GetServerConfig() ServerConfig {
+	return serverConfig
+}
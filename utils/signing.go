@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"math/big"
+	"os"
+)
+
+// signingKey signs ID tokens issued by the authorization server
+var signingKey *rsa.PrivateKey
+
+// LoadSigningKey reads the RSA private key (PKCS#1 or PKCS#8 PEM) from path,
+// when path is empty an ephemeral key is generated and tokens won't survive restarts
+func LoadSigningKey(path string) error {
+	if path == "" {
+		slog.Warn("OAUTH_SIGNING_KEY_FILE not set, using ephemeral signing key")
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return err
+		}
+		signingKey = key
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("signing key file is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		signingKey = key
+		return nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return errors.New("signing key must be an RSA key")
+	}
+	signingKey = key
+	return nil
+}
+
+// SigningKeyID is the RFC 7638 thumbprint of the public signing key
+func SigningKeyID() string {
+	jwk := signingPublicJWK()
+	thumbprintInput, _ := json.Marshal(map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N})
+	sum := sha256.Sum256(thumbprintInput)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// SigningJWKS returns the public signing key set published at the jwks endpoint
+func SigningJWKS() JSONWebKeySet {
+	jwk := signingPublicJWK()
+	jwk.Kid = SigningKeyID()
+	jwk.Use = "sig"
+	jwk.Alg = "RS256"
+	return JSONWebKeySet{Keys: []JSONWebKey{jwk}}
+}
+
+func signingPublicJWK() JSONWebKey {
+	publicKey := signingKey.PublicKey
+	return JSONWebKey{
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
+
+// SignJWS signs the claims with the RS256 signing key
+func SignJWS(claims any) (string, error) {
+	if signingKey == nil {
+		return "", errors.New("signing key is not loaded")
+	}
+
+	headerJSON, err := json.Marshal(JWSHeader{Alg: "RS256", Kid: SigningKeyID(), Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payloadJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signingKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSConfig enables native TLS serving, the server speaks plain HTTP
+// when CertFile is empty
+type TLSConfig struct {
+	CertFile       string
+	KeyFile        string
+	MinVersion     uint16
+	CipherSuites   []uint16      // TLS 1.2 only, TLS 1.3 suites are not configurable
+	ReloadInterval time.Duration // how often cert files are checked for changes
+	ClientCAFile   string        // enables client certificates (mTLS)
+	ClientAuth     tls.ClientAuthType
+	// ClientCertAuth authenticates requests without other credentials as the
+	// user matching the email SAN of the verified client certificate
+	ClientCertAuth bool
+	// HSTS is sent on TLS responses when HSTSMaxAge > 0
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+}
+
+var tlsConfig = TLSConfig{
+	MinVersion:     tls.VersionTLS12,
+	ReloadInterval: 30 * time.Second,
+	ClientAuth:     tls.NoClientCert,
+	HSTSMaxAge:     365 * 24 * time.Hour,
+}
+
+func SetTLSConfig(config TLSConfig) {
+	tlsConfig = config
+}
+
+func GetTLSConfig() TLSConfig {
+	return tlsConfig
+}
+
+// ParseTLSVersion parses "1.2" or "1.3"
+func ParseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.TrimSpace(version), "TLS") {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported tls version %q", version)
+}
+
+// ParseCipherSuites parses comma separated suite names like
+// "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", insecure suites are rejected
+func ParseCipherSuites(names string) ([]uint16, error) {
+	var suites []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// ParseClientAuth parses none, request, verify_if_given or require
+func ParseClientAuth(value string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return 0, fmt.Errorf("unsupported client auth %q", value)
+}
+
+// CertReloader serves the certificate from CertFile/KeyFile and reloads it
+// when the files change, so renewed certificates apply without a restart
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	reloader := &CertReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	modTime, err := reloader.filesModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := reloader.load(modTime); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// filesModTime returns the latest modification time of the cert and key files
+func (cr *CertReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (cr *CertReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %w", err)
+	}
+	cr.cert = &cert
+	cr.modTime = modTime
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, the files are checked
+// at most once per interval. A failed reload keeps serving the old certificate,
+// e.g. while the cert is written but the key is not yet
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if cr.interval > 0 && time.Since(cr.checkedAt) >= cr.interval {
+		cr.checkedAt = time.Now()
+		modTime, err := cr.filesModTime()
+		if err == nil && !modTime.Equal(cr.modTime) {
+			err = cr.load(modTime)
+			if err == nil {
+				slog.Info("tls certificate reloaded", "cert_file", cr.certFile)
+			}
+		}
+		if err != nil {
+			slog.Error("tls certificate reload failed", "cert_file", cr.certFile, "error", err)
+		}
+	}
+
+	return cr.cert, nil
+}
+
+// NewServerTLSConfig builds the *tls.Config for the server from config
+func NewServerTLSConfig(config TLSConfig) (*tls.Config, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("tls cert and key files are required")
+	}
+
+	reloader, err := NewCertReloader(config.CertFile, config.KeyFile, config.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	serverConfig := &tls.Config{
+		MinVersion:     config.MinVersion,
+		CipherSuites:   config.CipherSuites,
+		GetCertificate: reloader.GetCertificate,
+		ClientAuth:     config.ClientAuth,
+	}
+
+	if config.ClientCAFile != "" {
+		pem, err := os.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in client ca file")
+		}
+		serverConfig.ClientCAs = pool
+		if serverConfig.ClientAuth == tls.NoClientCert {
+			serverConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return serverConfig, nil
+}
+
+// ClientIdentity is the verified client certificate of a mTLS request
+type ClientIdentity struct {
+	Subject     string
+	CommonName  string
+	Emails      []string
+	DNSNames    []string
+	URIs        []string
+	Serial      string
+	Fingerprint string // hex sha256 of the certificate
+}
+
+// NewClientIdentity extracts the identity from a verified client certificate
+func NewClientIdentity(cert *x509.Certificate) *ClientIdentity {
+	fingerprint := sha256.Sum256(cert.Raw)
+	identity := &ClientIdentity{
+		Subject:     cert.Subject.String(),
+		CommonName:  cert.Subject.CommonName,
+		Emails:      cert.EmailAddresses,
+		DNSNames:    cert.DNSNames,
+		Serial:      cert.SerialNumber.String(),
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity
+}
+
+// Email returns the first email SAN, used to map the certificate to a user
+func (ci *ClientIdentity) Email() string {
+	if len(ci.Emails) > 0 {
+		return ci.Emails[0]
+	}
+	return ""
+}
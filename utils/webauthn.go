@@ -0,0 +1,465 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WebAuthnConfig describes the relying party
+type WebAuthnConfig struct {
+	RPID    string   // effective domain, e.g. example.com
+	RPName  string   // human readable name shown by authenticators
+	Origins []string // allowed origins, e.g. https://app.example.com
+	Timeout time.Duration
+}
+
+var webAuthnConfig = WebAuthnConfig{
+	RPID:    "localhost",
+	RPName:  "Go API Starter Kit",
+	Origins: []string{"http://localhost:8000"},
+	Timeout: 5 * time.Minute,
+}
+
+func SetWebAuthnConfig(config WebAuthnConfig) {
+	webAuthnConfig = config
+}
+
+func GetWebAuthnConfig() WebAuthnConfig {
+	return webAuthnConfig
+}
+
+// COSE algorithm identifiers
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+var WebAuthnAlgorithms = []int64{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// authenticator data flags
+const (
+	authDataFlagUserPresent  = 0x01
+	authDataFlagUserVerified = 0x04
+	authDataFlagAttested     = 0x40
+	authDataFlagExtensions   = 0x80
+)
+
+// AuthenticatorData is the parsed authenticatorData structure
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE encoded credential public key
+}
+
+func (a *AuthenticatorData) UserPresent() bool {
+	return a.Flags&authDataFlagUserPresent != 0
+}
+
+func (a *AuthenticatorData) UserVerified() bool {
+	return a.Flags&authDataFlagUserVerified != 0
+}
+
+// WebAuthnCredential is a verified credential ready to be stored
+type WebAuthnCredential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+	Format    string
+}
+
+// ClientData is the parsed clientDataJSON
+type ClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// ParseClientData decodes clientDataJSON without verifying it, used to read the challenge
+func ParseClientData(clientDataJSON []byte) (*ClientData, error) {
+	var clientData ClientData
+	err := json.Unmarshal(clientDataJSON, &clientData)
+	if err != nil {
+		return nil, err
+	}
+	return &clientData, nil
+}
+
+func verifyClientData(clientDataJSON []byte, ceremonyType, challenge string) error {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+	if clientData.Type != ceremonyType {
+		return errors.New("webauthn: unexpected ceremony type")
+	}
+	if clientData.Challenge != challenge {
+		return errors.New("webauthn: challenge mismatch")
+	}
+	if !slices.Contains(webAuthnConfig.Origins, clientData.Origin) {
+		return errors.New("webauthn: origin not allowed")
+	}
+	if clientData.CrossOrigin {
+		return errors.New("webauthn: cross origin requests are not allowed")
+	}
+	return nil
+}
+
+// ParseAuthenticatorData decodes authenticator data with optional attested credential data
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("webauthn: authenticator data too short")
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.Flags&authDataFlagAttested != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("webauthn: attested credential data too short")
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return nil, errors.New("webauthn: credential id too short")
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// public key is a CBOR map followed by optional extensions
+		_, remaining, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		authData.PublicKey = rest[:len(rest)-len(remaining)]
+		rest = remaining
+	}
+
+	if authData.Flags&authDataFlagExtensions != 0 {
+		_, remaining, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = remaining
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("webauthn: trailing authenticator data")
+	}
+
+	return authData, nil
+}
+
+func verifyAuthenticatorData(authData *AuthenticatorData, requireUserVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(webAuthnConfig.RPID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return errors.New("webauthn: rp id hash mismatch")
+	}
+	if !authData.UserPresent() {
+		return errors.New("webauthn: user not present")
+	}
+	if requireUserVerification && !authData.UserVerified() {
+		return errors.New("webauthn: user not verified")
+	}
+	return nil
+}
+
+// ParseCOSEKey converts a COSE encoded public key to a crypto public key and its algorithm
+func ParseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	decoded, rest, err := DecodeCBOR(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(rest) != 0 {
+		return nil, 0, errors.New("cose: trailing data")
+	}
+	key, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, 0, errors.New("cose: key is not a map")
+	}
+
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256: // EC2
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("cose: invalid P-256 key")
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, 0, errors.New("cose: invalid P-256 point")
+		}
+		return publicKey, alg, nil
+	case kty == 1 && alg == COSEAlgEdDSA: // OKP
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("cose: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == 3 && alg == COSEAlgRS256: // RSA
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("cose: invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	}
+
+	return nil, 0, fmt.Errorf("cose: unsupported key type %d with algorithm %d", kty, alg)
+}
+
+// verifyCOSESignature checks a WebAuthn signature, ES256 signatures are ASN.1 DER encoded
+func verifyCOSESignature(alg int64, publicKey crypto.PublicKey, data, signature []byte) error {
+	switch alg {
+	case COSEAlgES256:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("webauthn: key type does not match algorithm")
+		}
+		digest := sha256.Sum256(data)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("webauthn: invalid signature")
+		}
+		return nil
+	case COSEAlgEdDSA:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("webauthn: key type does not match algorithm")
+		}
+		if !ed25519.Verify(key, data, signature) {
+			return errors.New("webauthn: invalid signature")
+		}
+		return nil
+	case COSEAlgRS256:
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("webauthn: key type does not match algorithm")
+		}
+		digest := sha256.Sum256(data)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return errors.New("webauthn: invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("webauthn: unsupported algorithm %d", alg)
+}
+
+// VerifyWebAuthnRegistration verifies the attestation response of a registration
+// ceremony, supported attestation formats are "none" and "packed"
+func VerifyWebAuthnRegistration(challenge string, clientDataJSON, attestationObject []byte) (*WebAuthnCredential, error) {
+	err := verifyClientData(clientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, rest, err := DecodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := decoded.(map[any]any)
+	if !ok || len(rest) != 0 {
+		return nil, errors.New("webauthn: invalid attestation object")
+	}
+	format, _ := attestation["fmt"].(string)
+	rawAuthData, _ := attestation["authData"].([]byte)
+	statement, _ := attestation["attStmt"].(map[any]any)
+	if rawAuthData == nil || statement == nil {
+		return nil, errors.New("webauthn: invalid attestation object")
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyAuthenticatorData(authData, false)
+	if err != nil {
+		return nil, err
+	}
+	if authData.CredentialID == nil {
+		return nil, errors.New("webauthn: attested credential data is missing")
+	}
+	if len(authData.CredentialID) > 1023 {
+		return nil, errors.New("webauthn: credential id too long")
+	}
+
+	credentialKey, credentialAlg, err := ParseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return nil, errors.New("webauthn: none attestation must have empty statement")
+		}
+	case "packed":
+		clientDataHash := sha256.Sum256(clientDataJSON)
+		signedData := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+		err = verifyPackedAttestation(statement, signedData, credentialKey, credentialAlg, authData.AAGUID)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("webauthn: unsupported attestation format %q", format)
+	}
+
+	return &WebAuthnCredential{
+		ID:        authData.CredentialID,
+		PublicKey: authData.PublicKey,
+		SignCount: authData.SignCount,
+		AAGUID:    authData.AAGUID,
+		Format:    format,
+	}, nil
+}
+
+// verifyPackedAttestation handles both self attestation and basic attestation with x5c,
+// the attestation certificate chain is not evaluated against trust anchors
+func verifyPackedAttestation(statement map[any]any, signedData []byte, credentialKey crypto.PublicKey, credentialAlg int64, aaguid []byte) error {
+	alg, ok := statement["alg"].(int64)
+	if !ok {
+		return errors.New("webauthn: packed attestation has no alg")
+	}
+	signature, ok := statement["sig"].([]byte)
+	if !ok {
+		return errors.New("webauthn: packed attestation has no sig")
+	}
+
+	x5c, hasX5C := statement["x5c"].([]any)
+	if !hasX5C {
+		// self attestation is signed by the credential key itself
+		if alg != credentialAlg {
+			return errors.New("webauthn: self attestation algorithm mismatch")
+		}
+		return verifyCOSESignature(alg, credentialKey, signedData, signature)
+	}
+
+	if len(x5c) == 0 {
+		return errors.New("webauthn: empty x5c")
+	}
+	certDER, ok := x5c[0].([]byte)
+	if !ok {
+		return errors.New("webauthn: invalid x5c")
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return err
+	}
+
+	// requirements for packed attestation certificates
+	if cert.Version != 3 || cert.IsCA || !slices.Contains(cert.Subject.OrganizationalUnit, "Authenticator Attestation") {
+		return errors.New("webauthn: invalid attestation certificate")
+	}
+	if time.Now().Before(cert.NotBefore) || time.Now().After(cert.NotAfter) {
+		return errors.New("webauthn: attestation certificate expired")
+	}
+	// id-fido-gen-ce-aaguid extension must match when present
+	for _, extension := range cert.Extensions {
+		if extension.Id.String() == "1.3.6.1.4.1.45724.1.1.4" {
+			// value is an OCTET STRING wrapping the 16 byte aaguid
+			if len(extension.Value) != 18 || !bytes.Equal(extension.Value[2:], aaguid) {
+				return errors.New("webauthn: attestation certificate aaguid mismatch")
+			}
+		}
+	}
+
+	return verifyCOSESignature(alg, cert.PublicKey, signedData, signature)
+}
+
+// VerifyWebAuthnAssertion verifies an authentication assertion and returns the new sign count,
+// a sign count that didn't increase indicates a cloned authenticator
+func VerifyWebAuthnAssertion(challenge string, publicKey []byte, storedSignCount uint32, clientDataJSON, rawAuthData, signature []byte) (uint32, error) {
+	err := verifyClientData(clientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	err = verifyAuthenticatorData(authData, false)
+	if err != nil {
+		return 0, err
+	}
+
+	key, alg, err := ParseCOSEKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signedData := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	err = verifyCOSESignature(alg, key, signedData, signature)
+	if err != nil {
+		return 0, err
+	}
+
+	// authenticators that don't implement counters always report zero
+	if (authData.SignCount != 0 || storedSignCount != 0) && authData.SignCount <= storedSignCount {
+		return 0, errors.New("webauthn: sign count did not increase, authenticator may be cloned")
+	}
+
+	return authData.SignCount, nil
+}
+
+// EncodeWebAuthnBytes encodes binary values for JSON options and responses
+func EncodeWebAuthnBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeWebAuthnBytes accepts base64url with or without padding
+func DecodeWebAuthnBytes(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return base64.URLEncoding.DecodeString(s)
+	}
+	return b, nil
+}
+
+// FakeWebAuthnCredentialIDs derives stable credential ids for an email without
+// passkeys, so login options don't reveal whether an account exists
+func FakeWebAuthnCredentialIDs(email string) [][]byte {
+	h := hmac.New(sha256.New, secretKey)
+	h.Write([]byte("webauthn-fake:" + strings.ToLower(strings.TrimSpace(email))))
+	seed := h.Sum(nil)
+
+	ids := make([][]byte, 1+int(seed[0]%2))
+	for i := range ids {
+		h := hmac.New(sha256.New, secretKey)
+		h.Write(seed)
+		h.Write([]byte{byte(i)})
+		ids[i] = h.Sum(nil)
+	}
+	return ids
+}
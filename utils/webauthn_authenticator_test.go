@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// softAuthenticator is a software WebAuthn authenticator for tests, it
+// creates credentials and signs assertions like a security key would
+type softAuthenticator struct {
+	alg          int64
+	signer       crypto.Signer
+	credentialID []byte
+	aaguid       []byte
+	signCount    uint32
+	// counterless authenticators always report a zero sign count
+	counterless bool
+}
+
+func newSoftAuthenticator(t *testing.T, alg int64) *softAuthenticator {
+	t.Helper()
+	var signer crypto.Signer
+	var err error
+	switch alg {
+	case COSEAlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case COSEAlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	case COSEAlgRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 32)
+	rand.Read(credentialID)
+	return &softAuthenticator{
+		alg:          alg,
+		signer:       signer,
+		credentialID: credentialID,
+		aaguid:       make([]byte, 16),
+	}
+}
+
+// coseKey encodes the credential public key as a COSE_Key
+func (a *softAuthenticator) coseKey() []byte {
+	switch key := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		return encodeCBOR(cborMap{
+			{int64(1), int64(2)},
+			{int64(3), a.alg},
+			{int64(-1), int64(1)},
+			{int64(-2), key.X.FillBytes(make([]byte, 32))},
+			{int64(-3), key.Y.FillBytes(make([]byte, 32))},
+		})
+	case ed25519.PublicKey:
+		return encodeCBOR(cborMap{
+			{int64(1), int64(1)},
+			{int64(3), a.alg},
+			{int64(-1), int64(6)},
+			{int64(-2), []byte(key)},
+		})
+	case *rsa.PublicKey:
+		return encodeCBOR(cborMap{
+			{int64(1), int64(3)},
+			{int64(3), a.alg},
+			{int64(-1), key.N.Bytes()},
+			{int64(-2), big.NewInt(int64(key.E)).Bytes()},
+		})
+	}
+	panic("unsupported key")
+}
+
+func (a *softAuthenticator) sign(data []byte) []byte {
+	var signature []byte
+	var err error
+	switch a.alg {
+	case COSEAlgEdDSA:
+		signature, err = a.signer.Sign(rand.Reader, data, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(data)
+		signature, err = a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+func (a *softAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, a.aaguid...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func clientDataJSON(ceremonyType, challenge, origin string) []byte {
+	data, _ := json.Marshal(ClientData{Type: ceremonyType, Challenge: challenge, Origin: origin})
+	return data
+}
+
+// register answers navigator.credentials.create with a "none" or self
+// attested "packed" attestation object
+func (a *softAuthenticator) register(rpID, origin, challenge, format string) (clientData, attestationObject []byte) {
+	clientData = clientDataJSON("webauthn.create", challenge, origin)
+	if !a.counterless {
+		a.signCount++
+	}
+	authData := a.authData(rpID, authDataFlagUserPresent|authDataFlagUserVerified|authDataFlagAttested, true)
+
+	statement := cborMap{}
+	if format == "packed" {
+		clientDataHash := sha256.Sum256(clientData)
+		signature := a.sign(append(append([]byte{}, authData...), clientDataHash[:]...))
+		statement = cborMap{{"alg", a.alg}, {"sig", signature}}
+	}
+	attestationObject = encodeCBOR(cborMap{
+		{"fmt", format},
+		{"attStmt", statement},
+		{"authData", authData},
+	})
+	return clientData, attestationObject
+}
+
+// assert answers navigator.credentials.get
+func (a *softAuthenticator) assert(rpID, origin, challenge string) (clientData, authData, signature []byte) {
+	clientData = clientDataJSON("webauthn.get", challenge, origin)
+	if !a.counterless {
+		a.signCount++
+	}
+	authData = a.authData(rpID, authDataFlagUserPresent|authDataFlagUserVerified, false)
+	clientDataHash := sha256.Sum256(clientData)
+	signature = a.sign(append(append([]byte{}, authData...), clientDataHash[:]...))
+	return clientData, authData, signature
+}
+
+// cborMap keeps the key order, WebAuthn uses canonical CBOR
+type cborMap []struct {
+	key   any
+	value any
+}
+
+// encodeCBOR encodes the subset DecodeCBOR supports
+func encodeCBOR(value any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xff:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xffff:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		case n <= 0xffffffff:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+	}
+
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []any:
+		out := head(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case cborMap:
+		out := head(5, uint64(len(v)))
+		for _, pair := range v {
+			out = append(out, encodeCBOR(pair.key)...)
+			out = append(out, encodeCBOR(pair.value)...)
+		}
+		return out
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	}
+	panic(fmt.Sprintf("cbor: unsupported type %T", value))
+}
@@ -0,0 +1,279 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+const (
+	testRPID      = "example.com"
+	testOrigin    = "https://example.com"
+	testChallenge = "c2lnbi1tZS1wbGVhc2U"
+)
+
+func setTestWebAuthnConfig(t *testing.T) {
+	t.Helper()
+	previous := GetWebAuthnConfig()
+	SetWebAuthnConfig(WebAuthnConfig{RPID: testRPID, RPName: "Example", Origins: []string{testOrigin}})
+	t.Cleanup(func() { SetWebAuthnConfig(previous) })
+}
+
+var testAlgorithms = []struct {
+	name string
+	alg  int64
+}{
+	{"ES256", COSEAlgES256},
+	{"EdDSA", COSEAlgEdDSA},
+	{"RS256", COSEAlgRS256},
+}
+
+func TestVerifyWebAuthnRegistration(t *testing.T) {
+	setTestWebAuthnConfig(t)
+
+	for _, algorithm := range testAlgorithms {
+		for _, format := range []string{"none", "packed"} {
+			t.Run(algorithm.name+"/"+format, func(t *testing.T) {
+				authenticator := newSoftAuthenticator(t, algorithm.alg)
+				clientData, attestationObject := authenticator.register(testRPID, testOrigin, testChallenge, format)
+
+				credential, err := VerifyWebAuthnRegistration(testChallenge, clientData, attestationObject)
+				if err != nil {
+					t.Fatalf("registration failed: %v", err)
+				}
+				if !bytes.Equal(credential.ID, authenticator.credentialID) {
+					t.Errorf("credential id = %x, want %x", credential.ID, authenticator.credentialID)
+				}
+				if credential.Format != format {
+					t.Errorf("format = %q, want %q", credential.Format, format)
+				}
+				if credential.SignCount != authenticator.signCount {
+					t.Errorf("sign count = %d, want %d", credential.SignCount, authenticator.signCount)
+				}
+				_, alg, err := ParseCOSEKey(credential.PublicKey)
+				if err != nil || alg != algorithm.alg {
+					t.Errorf("stored public key alg = %d, err = %v, want %d", alg, err, algorithm.alg)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyWebAuthnRegistrationRejects(t *testing.T) {
+	setTestWebAuthnConfig(t)
+	authenticator := newSoftAuthenticator(t, COSEAlgES256)
+
+	tests := []struct {
+		name      string
+		challenge string
+		rpID      string
+		origin    string
+		format    string
+		tamper    func(clientData, attestationObject []byte) ([]byte, []byte)
+	}{
+		{name: "challenge mismatch", challenge: "b3RoZXI"},
+		{name: "origin not allowed", origin: "https://evil.example"},
+		{name: "rp id mismatch", rpID: "evil.example"},
+		{name: "unsupported format", format: "fido-u2f"},
+		{
+			name: "get ceremony",
+			tamper: func(_, attestationObject []byte) ([]byte, []byte) {
+				return clientDataJSON("webauthn.get", testChallenge, testOrigin), attestationObject
+			},
+		},
+		{
+			name:   "packed signature over other client data",
+			format: "packed",
+			tamper: func(clientData, attestationObject []byte) ([]byte, []byte) {
+				// still valid client data, but not what the authenticator signed
+				return append(clientData, ' '), attestationObject
+			},
+		},
+		{
+			name: "trailing attestation bytes",
+			tamper: func(clientData, attestationObject []byte) ([]byte, []byte) {
+				return clientData, append(attestationObject, 0)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpID, origin, format := testRPID, testOrigin, "none"
+			if tt.rpID != "" {
+				rpID = tt.rpID
+			}
+			if tt.origin != "" {
+				origin = tt.origin
+			}
+			if tt.format != "" {
+				format = tt.format
+			}
+			clientData, attestationObject := authenticator.register(rpID, origin, testChallenge, format)
+			if tt.tamper != nil {
+				clientData, attestationObject = tt.tamper(clientData, attestationObject)
+			}
+			challenge := testChallenge
+			if tt.challenge != "" {
+				challenge = tt.challenge
+			}
+
+			_, err := VerifyWebAuthnRegistration(challenge, clientData, attestationObject)
+			if err == nil {
+				t.Fatal("registration succeeded, want error")
+			}
+		})
+	}
+}
+
+// registerSoftAuthenticator registers a new authenticator and returns the
+// stored credential
+func registerSoftAuthenticator(t *testing.T, alg int64, counterless bool) (*softAuthenticator, *WebAuthnCredential) {
+	t.Helper()
+	authenticator := newSoftAuthenticator(t, alg)
+	authenticator.counterless = counterless
+	clientData, attestationObject := authenticator.register(testRPID, testOrigin, testChallenge, "none")
+	credential, err := VerifyWebAuthnRegistration(testChallenge, clientData, attestationObject)
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	return authenticator, credential
+}
+
+func TestVerifyWebAuthnAssertion(t *testing.T) {
+	setTestWebAuthnConfig(t)
+
+	for _, algorithm := range testAlgorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			authenticator, credential := registerSoftAuthenticator(t, algorithm.alg, false)
+
+			signCount := credential.SignCount
+			for range 2 {
+				clientData, authData, signature := authenticator.assert(testRPID, testOrigin, testChallenge)
+				next, err := VerifyWebAuthnAssertion(testChallenge, credential.PublicKey, signCount, clientData, authData, signature)
+				if err != nil {
+					t.Fatalf("assertion failed: %v", err)
+				}
+				if next != authenticator.signCount {
+					t.Fatalf("sign count = %d, want %d", next, authenticator.signCount)
+				}
+				signCount = next
+			}
+		})
+	}
+}
+
+func TestVerifyWebAuthnAssertionRejects(t *testing.T) {
+	setTestWebAuthnConfig(t)
+
+	for _, algorithm := range testAlgorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			authenticator, credential := registerSoftAuthenticator(t, algorithm.alg, false)
+			other, _ := registerSoftAuthenticator(t, algorithm.alg, false)
+
+			tests := []struct {
+				name      string
+				challenge string
+				origin    string
+				signer    *softAuthenticator
+				tamper    func(authData, signature []byte)
+			}{
+				{name: "challenge mismatch", challenge: "b3RoZXI"},
+				{name: "origin not allowed", origin: "https://evil.example"},
+				{name: "other credential", signer: other},
+				{name: "tampered signature", tamper: func(_, signature []byte) { signature[len(signature)-1] ^= 0xff }},
+				{name: "tampered sign count", tamper: func(authData, _ []byte) { authData[36]++ }},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					origin, signer := testOrigin, authenticator
+					if tt.origin != "" {
+						origin = tt.origin
+					}
+					if tt.signer != nil {
+						signer = tt.signer
+					}
+					clientData, authData, signature := signer.assert(testRPID, origin, testChallenge)
+					if tt.tamper != nil {
+						tt.tamper(authData, signature)
+					}
+					challenge := testChallenge
+					if tt.challenge != "" {
+						challenge = tt.challenge
+					}
+
+					_, err := VerifyWebAuthnAssertion(challenge, credential.PublicKey, 0, clientData, authData, signature)
+					if err == nil {
+						t.Fatal("assertion succeeded, want error")
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestVerifyWebAuthnAssertionSignCount(t *testing.T) {
+	setTestWebAuthnConfig(t)
+
+	t.Run("regression rejected", func(t *testing.T) {
+		authenticator, credential := registerSoftAuthenticator(t, COSEAlgES256, false)
+		clientData, authData, signature := authenticator.assert(testRPID, testOrigin, testChallenge)
+		signCount, err := VerifyWebAuthnAssertion(testChallenge, credential.PublicKey, credential.SignCount, clientData, authData, signature)
+		if err != nil {
+			t.Fatalf("assertion failed: %v", err)
+		}
+
+		// a clone replays the same counter value
+		_, err = VerifyWebAuthnAssertion(testChallenge, credential.PublicKey, signCount, clientData, authData, signature)
+		if err == nil {
+			t.Fatal("replayed sign count accepted")
+		}
+
+		// a clone that fell behind the original
+		authenticator.signCount = signCount - 1
+		clientData, authData, signature = authenticator.assert(testRPID, testOrigin, testChallenge)
+		_, err = VerifyWebAuthnAssertion(testChallenge, credential.PublicKey, signCount, clientData, authData, signature)
+		if err == nil {
+			t.Fatal("lower sign count accepted")
+		}
+	})
+
+	t.Run("counterless authenticator", func(t *testing.T) {
+		authenticator, credential := registerSoftAuthenticator(t, COSEAlgEdDSA, true)
+		for range 2 {
+			clientData, authData, signature := authenticator.assert(testRPID, testOrigin, testChallenge)
+			signCount, err := VerifyWebAuthnAssertion(testChallenge, credential.PublicKey, credential.SignCount, clientData, authData, signature)
+			if err != nil {
+				t.Fatalf("assertion failed: %v", err)
+			}
+			if signCount != 0 {
+				t.Fatalf("sign count = %d, want 0", signCount)
+			}
+		}
+	})
+
+	t.Run("counter reset to zero rejected", func(t *testing.T) {
+		authenticator, credential := registerSoftAuthenticator(t, COSEAlgRS256, true)
+		clientData, authData, signature := authenticator.assert(testRPID, testOrigin, testChallenge)
+		_, err := VerifyWebAuthnAssertion(testChallenge, credential.PublicKey, 5, clientData, authData, signature)
+		if err == nil {
+			t.Fatal("zero sign count accepted after a non zero one")
+		}
+	})
+}
+
+func TestFakeWebAuthnCredentialIDs(t *testing.T) {
+	first := FakeWebAuthnCredentialIDs("Nobody@Example.com")
+	second := FakeWebAuthnCredentialIDs(" nobody@example.com")
+	if len(first) == 0 || len(first) != len(second) {
+		t.Fatalf("got %d and %d ids, want the same non zero count", len(first), len(second))
+	}
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			t.Fatalf("id %d differs between calls for the same email", i)
+		}
+	}
+	if other := FakeWebAuthnCredentialIDs("somebody@example.com"); bytes.Equal(other[0], first[0]) {
+		t.Fatal("different emails got the same id")
+	}
+}
@@ -0,0 +1,286 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+// RuleFunc validates a single field, param is the text after "=" in the tag,
+// parent is the struct holding the field for cross field rules.
+// It returns an empty string when the value is valid or the failure message
+type RuleFunc func(field reflect.Value, param string, parent reflect.Value) string
+
+var (
+	rules   = map[string]RuleFunc{}
+	rulesMu sync.RWMutex
+)
+
+// RegisterRule adds a custom rule usable in `validate` tags
+func RegisterRule(name string, rule RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = rule
+}
+
+func init() {
+	RegisterRule("required", required)
+	RegisterRule("required_without", requiredWithout)
+	RegisterRule("min", minRule)
+	RegisterRule("max", maxRule)
+	RegisterRule("email", email)
+	RegisterRule("url", absoluteURL)
+	RegisterRule("eqfield", eqField)
+	RegisterRule("oneof", oneOf)
+	RegisterRule("password", password)
+}
+
+// Validate checks the struct against its `validate` tags, e.g.
+//
+//	Email string `json:"email" validate:"required,email,max=254"`
+//
+// and returns a validation APIError listing every invalid field, or nil
+func Validate(v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: expected struct, got %s", value.Kind())
+	}
+
+	fieldErrors := []utils.FieldError{}
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		tag := structField.Tag.Get("validate")
+		if tag == "" || tag == "-" || !structField.IsExported() {
+			continue
+		}
+
+		field := value.Field(i)
+		name := fieldName(structField)
+
+		// optional fields skip the remaining rules when empty
+		rulesList := strings.Split(tag, ",")
+		if !slices.Contains(rulesList, "required") && !hasRulePrefix(rulesList, "required_") && isEmpty(field) {
+			continue
+		}
+
+		for _, rule := range rulesList {
+			ruleName, param, _ := strings.Cut(rule, "=")
+
+			rulesMu.RLock()
+			ruleFunc, ok := rules[ruleName]
+			rulesMu.RUnlock()
+			if !ok {
+				return fmt.Errorf("validation: unknown rule %q on field %s", ruleName, structField.Name)
+			}
+
+			if message := ruleFunc(field, param, value); message != "" {
+				fieldErrors = append(fieldErrors, utils.FieldError{
+					Field:   name,
+					Code:    ruleName,
+					Message: message,
+				})
+				// report only the first failing rule per field
+				break
+			}
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return utils.NewValidationError(fieldErrors...)
+	}
+	return nil
+}
+
+// fieldName returns the json name of the field so errors match the request body
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func fieldNameByGoName(parent reflect.Value, goName string) string {
+	structField, ok := parent.Type().FieldByName(goName)
+	if !ok {
+		return goName
+	}
+	return fieldName(structField)
+}
+
+func hasRulePrefix(rulesList []string, prefix string) bool {
+	for _, rule := range rulesList {
+		if strings.HasPrefix(rule, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmpty(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(field.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return field.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return field.IsNil()
+	}
+	return field.IsZero()
+}
+
+// size is string length in characters, collection length or numeric value
+func size(field reflect.Value) (float64, bool) {
+	switch field.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(field.String())), true
+	case reflect.Slice, reflect.Map:
+		return float64(field.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(field.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(field.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return field.Float(), true
+	}
+	return 0, false
+}
+
+func sizeUnit(field reflect.Value) string {
+	switch field.Kind() {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Map:
+		return " items"
+	}
+	return ""
+}
+
+func required(field reflect.Value, param string, parent reflect.Value) string {
+	if isEmpty(field) {
+		return "is required"
+	}
+	return ""
+}
+
+func requiredWithout(field reflect.Value, param string, parent reflect.Value) string {
+	other := parent.FieldByName(param)
+	if isEmpty(field) && (!other.IsValid() || isEmpty(other)) {
+		return "is required when " + fieldNameByGoName(parent, param) + " is empty"
+	}
+	return ""
+}
+
+func minRule(field reflect.Value, param string, parent reflect.Value) string {
+	limit, err := strconv.ParseFloat(param, 64)
+	value, ok := size(field)
+	if err != nil || !ok {
+		return "has unsupported min rule"
+	}
+	if value < limit {
+		return "must be at least " + param + sizeUnit(field)
+	}
+	return ""
+}
+
+func maxRule(field reflect.Value, param string, parent reflect.Value) string {
+	limit, err := strconv.ParseFloat(param, 64)
+	value, ok := size(field)
+	if err != nil || !ok {
+		return "has unsupported max rule"
+	}
+	if value > limit {
+		return "must be at most " + param + sizeUnit(field)
+	}
+	return ""
+}
+
+func email(field reflect.Value, param string, parent reflect.Value) string {
+	value := field.String()
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+func absoluteURL(field reflect.Value, param string, parent reflect.Value) string {
+	values := []string{}
+	switch field.Kind() {
+	case reflect.String:
+		values = append(values, field.String())
+	case reflect.Slice:
+		for i := 0; i < field.Len(); i++ {
+			values = append(values, field.Index(i).String())
+		}
+	}
+
+	for _, value := range values {
+		parsed, err := url.Parse(value)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" || strings.ContainsAny(value, " ") {
+			return "must be an absolute URL without fragment"
+		}
+	}
+	return ""
+}
+
+func eqField(field reflect.Value, param string, parent reflect.Value) string {
+	other := parent.FieldByName(param)
+	if !other.IsValid() || !reflect.DeepEqual(field.Interface(), other.Interface()) {
+		return "must match " + fieldNameByGoName(parent, param)
+	}
+	return ""
+}
+
+// Subset returns a rule accepting string slices whose items are all in allowed,
+// useful when the allowed values live in a package level list
+func Subset(allowed []string) RuleFunc {
+	return func(field reflect.Value, param string, parent reflect.Value) string {
+		for i := 0; i < field.Len(); i++ {
+			if !slices.Contains(allowed, field.Index(i).String()) {
+				return "must only contain: " + strings.Join(allowed, ", ")
+			}
+		}
+		return ""
+	}
+}
+
+// oneOf accepts space separated allowed values, for slices every item is checked
+func oneOf(field reflect.Value, param string, parent reflect.Value) string {
+	allowed := strings.Fields(param)
+	message := "must be one of: " + strings.Join(allowed, ", ")
+
+	switch field.Kind() {
+	case reflect.String:
+		if !slices.Contains(allowed, field.String()) {
+			return message
+		}
+	case reflect.Slice:
+		for i := 0; i < field.Len(); i++ {
+			if !slices.Contains(allowed, fmt.Sprint(field.Index(i).Interface())) {
+				return message
+			}
+		}
+	default:
+		if !slices.Contains(allowed, fmt.Sprint(field.Interface())) {
+			return message
+		}
+	}
+	return ""
+}
+
+func password(field reflect.Value, param string, parent reflect.Value) string {
+	if err := utils.ValidatePassword(field.String()); err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/olksndrdevhub/go-api-starter-kit/utils"
+)
+
+type signupRequest struct {
+	Email           string `json:"email" validate:"required,email,max=20"`
+	Name            string `json:"name" validate:"min=2,max=5"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password" validate:"eqfield=Password"`
+	Website         string `json:"website" validate:"url"`
+}
+
+type contactRequest struct {
+	Email string `json:"email" validate:"required_without=Phone"`
+	Phone string `json:"phone" validate:"required_without=Email"`
+}
+
+type listRequest struct {
+	Role   string   `json:"role" validate:"oneof=admin user"`
+	Limit  int      `json:"limit" validate:"min=1,max=100"`
+	Tags   []string `json:"tags" validate:"max=2,oneof=a b c"`
+	Scopes []string `json:"scopes" validate:"scopes_test"`
+	URLs   []string `json:"urls" validate:"url"`
+}
+
+func TestValidate(t *testing.T) {
+	RegisterRule("scopes_test", Subset([]string{"read", "write"}))
+
+	validSignup := signupRequest{Email: "user@example.com", Name: "Ann", Password: "secret", ConfirmPassword: "secret"}
+	signup := func(change func(*signupRequest)) signupRequest {
+		req := validSignup
+		change(&req)
+		return req
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		want  map[string]string // field to the code of its first failing rule
+	}{
+		{name: "valid signup", value: validSignup},
+		{name: "pointer to struct", value: &validSignup},
+		{name: "missing email", value: signup(func(r *signupRequest) { r.Email = "" }), want: map[string]string{"email": "required"}},
+		{name: "blank email", value: signup(func(r *signupRequest) { r.Email = "  " }), want: map[string]string{"email": "required"}},
+		{name: "invalid email", value: signup(func(r *signupRequest) { r.Email = "not-an-email" }), want: map[string]string{"email": "email"}},
+		{name: "email with display name", value: signup(func(r *signupRequest) { r.Email = "Ann <a@b.co>" }), want: map[string]string{"email": "email"}},
+		{name: "email too long", value: signup(func(r *signupRequest) { r.Email = "someone.long@example.com" }), want: map[string]string{"email": "max"}},
+		{name: "optional field empty", value: signup(func(r *signupRequest) { r.Name = "" })},
+		{name: "name too short", value: signup(func(r *signupRequest) { r.Name = "A" }), want: map[string]string{"name": "min"}},
+		{name: "max counts characters", value: signup(func(r *signupRequest) { r.Name = "Zoë" })},
+		{name: "name too long", value: signup(func(r *signupRequest) { r.Name = "Annabel" }), want: map[string]string{"name": "max"}},
+		{name: "passwords differ", value: signup(func(r *signupRequest) { r.ConfirmPassword = "other" }), want: map[string]string{"confirm_password": "eqfield"}},
+		{name: "absolute url", value: signup(func(r *signupRequest) { r.Website = "https://example.com/me" })},
+		{name: "relative url", value: signup(func(r *signupRequest) { r.Website = "/me" }), want: map[string]string{"website": "url"}},
+		{name: "url with fragment", value: signup(func(r *signupRequest) { r.Website = "https://example.com/#me" }), want: map[string]string{"website": "url"}},
+		{
+			name:  "every invalid field is reported",
+			value: signupRequest{Email: "bad", Name: "A", ConfirmPassword: "x", Website: "me"},
+			want:  map[string]string{"email": "email", "name": "min", "confirm_password": "eqfield", "website": "url"},
+		},
+
+		{name: "email without phone", value: contactRequest{Email: "user@example.com"}},
+		{name: "phone without email", value: contactRequest{Phone: "+380000000000"}},
+		{name: "neither email nor phone", value: contactRequest{}, want: map[string]string{"email": "required_without", "phone": "required_without"}},
+
+		{name: "valid list", value: listRequest{Role: "user", Limit: 10, Tags: []string{"a", "c"}, Scopes: []string{"read"}, URLs: []string{"https://a.example"}}},
+		{name: "role not allowed", value: listRequest{Role: "root", Limit: 1}, want: map[string]string{"role": "oneof"}},
+		{name: "limit below min", value: listRequest{Limit: -1}, want: map[string]string{"limit": "min"}},
+		{name: "limit above max", value: listRequest{Limit: 101}, want: map[string]string{"limit": "max"}},
+		{name: "too many tags", value: listRequest{Limit: 1, Tags: []string{"a", "b", "c"}}, want: map[string]string{"tags": "max"}},
+		{name: "tag not allowed", value: listRequest{Limit: 1, Tags: []string{"a", "d"}}, want: map[string]string{"tags": "oneof"}},
+		{name: "scope outside the subset", value: listRequest{Limit: 1, Scopes: []string{"read", "admin"}}, want: map[string]string{"scopes": "scopes_test"}},
+		{name: "relative url in list", value: listRequest{Limit: 1, URLs: []string{"https://a.example", "b"}}, want: map[string]string{"urls": "url"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.value)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+
+			var apiErr *utils.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != utils.ErrCodeValidation {
+				t.Fatalf("Validate = %v, want validation error", err)
+			}
+			got := map[string]string{}
+			for _, fieldErr := range apiErr.Errors {
+				got[fieldErr.Field] = fieldErr.Code
+				if fieldErr.Message == "" {
+					t.Errorf("field %s has no message", fieldErr.Field)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("field errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMisuse(t *testing.T) {
+	type unknownRule struct {
+		Name string `validate:"required,shiny"`
+	}
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"not a struct", "value"},
+		{"unknown rule", unknownRule{Name: "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.value)
+			var apiErr *utils.APIError
+			if err == nil || errors.As(err, &apiErr) {
+				t.Fatalf("Validate = %v, want a programming error", err)
+			}
+		})
+	}
+}